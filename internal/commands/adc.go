@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/output"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var adcCmd = &cobra.Command{
+	Use:   "adc",
+	Short: "Work with Application Default Credentials",
+}
+
+var adcInspectCmd = &cobra.Command{
+	Use:   "inspect [name]",
+	Short: "Show whose credentials an ADC file holds and check them against a configuration",
+	Long: `Show the type, account, impersonated service account and delegation chain of the
+ADC saved for a configuration, or of the live ADC when no name is given, and check
+that they match the account, service account and delegates of the configuration
+(the active one for the live ADC). Nothing is sent to Google.
+
+Exits with an error when the ADC does not match the configuration.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: GetConfigNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		var cfg *config.GCloudConfig
+		view := adcView{}
+		if len(args) == 1 {
+			if cfg, err = resolveConfig(store, args[0]); err != nil {
+				return err
+			}
+			account := cfg.Account
+			if account == "" {
+				account, _ = gcloud.GetAccountFromConfiguration(ctx, cfg.Name)
+			}
+			view.Path = configADCPath(cfg, account, cfg.Name == store.ActiveConfig)
+			if _, err := os.Stat(view.Path); view.Path == "" || err != nil {
+				return fmt.Errorf("configuration '%s' has no saved ADC, switch to it once to log in", cfg.Name)
+			}
+		} else {
+			if view.Path, err = gcloud.GetADCPath(); err != nil {
+				return err
+			}
+			if active, err := store.FindConfig(store.ActiveConfig); err == nil {
+				cfg = active
+			}
+		}
+
+		identity, err := gcloud.ReadADCIdentityFile(view.Path)
+		if err != nil {
+			return err
+		}
+		view.Type = credentialType(identity.Type)
+		view.Account = identity.Account
+		view.ServiceAccount = identity.ServiceAccount
+		view.Delegates = append([]string{}, identity.Delegates...)
+		view.Problems = []string{}
+		if err := config.ValidateDelegates(identity.Delegates, identity.ServiceAccount); err != nil {
+			view.Problems = append(view.Problems, err.Error())
+		}
+		if cfg != nil {
+			view.Configuration = cfg.Name
+			view.Problems = append(view.Problems, identityMismatches(cfg, identity)...)
+		}
+
+		var mismatchErr error
+		if len(view.Problems) > 0 {
+			mismatchErr = fmt.Errorf("ADC does not match configuration '%s'", view.Configuration)
+			if cfg == nil {
+				mismatchErr = fmt.Errorf("ADC is invalid")
+			}
+		}
+		if !format.IsText() {
+			if err := output.Write(cmd.OutOrStdout(), format, view); err != nil {
+				return err
+			}
+			return mismatchErr
+		}
+
+		logger.Info("ADC file", "path", view.Path)
+		logger.Info("  Type", "type", view.Type)
+		if view.Account != "" {
+			logger.Info("  Account", "account", view.Account)
+		}
+		if view.ServiceAccount != "" {
+			logger.Info("  Service Account", "service_account", view.ServiceAccount)
+		}
+		if len(view.Delegates) > 0 {
+			logger.Info("  Via Delegates", "delegates", strings.Join(view.Delegates, " → "))
+		}
+		for _, problem := range view.Problems {
+			logger.Warning(problem)
+		}
+		if mismatchErr == nil && cfg != nil {
+			logger.Success("ADC matches the configuration", "name", cfg.Name)
+		}
+		return mismatchErr
+	},
+}
+
+func init() {
+	adcCmd.AddCommand(adcInspectCmd)
+}
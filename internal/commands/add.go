@@ -15,6 +15,15 @@ import (
 var (
 	projectID      string
 	serviceAccount string
+	protected      bool
+	addVerify      bool
+	addTags        []string
+	addAliases     []string
+	addBrowserMode string
+	addAccount     string
+	addDelegates   []string
+	addADCScopes   []string
+	addClientID    string
 )
 var addCmd = &cobra.Command{
 	Use:   "add <name>",
@@ -24,6 +33,7 @@ service account for impersonation. If a native gcloud configuration with the
 same name already exists, it will be imported.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
 		configName := args[0]
 
 		store, err := config.LoadConfigStore()
@@ -32,7 +42,7 @@ same name already exists, it will be imported.`,
 		}
 
 		// Check if a native gcloud configuration already exists
-		configExists := gcloud.ConfigurationExists(configName)
+		configExists := gcloud.ConfigurationExists(ctx, configName)
 
 		var finalProjectID string
 
@@ -41,14 +51,14 @@ same name already exists, it will be imported.`,
 			logger.Info("Found existing gcloud configuration", "name", configName)
 
 			// Get the project ID from the existing configuration
-			existingProject, err := gcloud.GetProjectFromConfiguration(configName)
+			existingProject, err := gcloud.GetProjectFromConfiguration(ctx, configName)
 			if err != nil || existingProject == "" {
 				// If we can't get the project from the config, still allow user to set it
 				logger.Warning("Could not retrieve project from existing configuration")
 
 				reader := bufio.NewReader(os.Stdin)
 				if projectID == "" {
-					fmt.Print("Enter Project ID: ")
+					_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Enter Project ID: ") //nolint:errcheck
 					projectID, _ = reader.ReadString('\n')
 					projectID = strings.TrimSpace(projectID)
 				}
@@ -71,7 +81,7 @@ same name already exists, it will be imported.`,
 			reader := bufio.NewReader(os.Stdin)
 
 			if projectID == "" {
-				fmt.Print("Enter Project ID: ")
+				_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Enter Project ID: ") //nolint:errcheck
 				projectID, _ = reader.ReadString('\n')
 				projectID = strings.TrimSpace(projectID)
 			}
@@ -85,15 +95,67 @@ same name already exists, it will be imported.`,
 		// Service account is optional and can be set later via edit
 		if serviceAccount == "" && !cmd.Flags().Changed("service-account") && !configExists {
 			reader := bufio.NewReader(os.Stdin)
-			fmt.Print("Enter Service Account (optional, press Enter to skip): ")
+			_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Enter Service Account (optional, press Enter to skip): ") //nolint:errcheck
 			serviceAccount, _ = reader.ReadString('\n')
 			serviceAccount = strings.TrimSpace(serviceAccount)
 		}
 
+		delegates := parseListFlag(addDelegates)
+		if err := validateConfigValues(finalProjectID, serviceAccount, delegates); err != nil {
+			return err
+		}
+		// An imported configuration keeps its account unless another one is given
+		account := addAccount
+		if account == "" && configExists {
+			account, _ = gcloud.GetAccountFromConfiguration(ctx, configName)
+		}
+		if err := config.ValidateAccount(account); err != nil {
+			return err
+		}
+		tags, err := config.ParseTags(addTags)
+		if err != nil {
+			return err
+		}
+		aliases, err := config.ParseAliases(addAliases)
+		if err != nil {
+			return err
+		}
+		browserMode, err := parseBrowserModeFlag(addBrowserMode)
+		if err != nil {
+			return err
+		}
+		adcScopes := parseListFlag(addADCScopes)
+		if err := config.ValidateScopes(adcScopes); err != nil {
+			return err
+		}
+		clientIDFile, err := parseClientIDFile(addClientID)
+		if err != nil {
+			return err
+		}
+		if store.NameInUse(configName, "") {
+			return fmt.Errorf("configuration or alias '%s' already exists", configName)
+		}
+		if err := checkAliases(store, configName, aliases); err != nil {
+			return err
+		}
+		if addVerify {
+			if err := verifyConfigValues(ctx, finalProjectID, serviceAccount, delegates); err != nil {
+				return fmt.Errorf("verification failed, configuration not added:\n%w", err)
+			}
+		}
+
 		newConfig := config.GCloudConfig{
 			Name:           configName,
 			ProjectID:      finalProjectID,
 			ServiceAccount: serviceAccount,
+			Delegates:      delegates,
+			Account:        account,
+			Protected:      protected,
+			Tags:           tags,
+			Aliases:        aliases,
+			BrowserMode:    browserMode,
+			ADCScopes:      adcScopes,
+			ClientIDFile:   clientIDFile,
 		}
 
 		if err := store.AddConfig(newConfig); err != nil {
@@ -103,9 +165,15 @@ same name already exists, it will be imported.`,
 		// Create the native gcloud configuration only if it doesn't exist
 		if !configExists {
 			logger.Info("Creating gcloud configuration", "name", configName)
-			if err := gcloud.CreateConfiguration(configName); err != nil {
+			if err := gcloud.CreateConfiguration(ctx, configName); err != nil {
 				return fmt.Errorf("failed to create gcloud configuration: %w", err)
 			}
+			store.MarkOwned(configName)
+		}
+		if addAccount != "" {
+			if err := gcloud.SetConfigurationProperty(ctx, configName, "core/account", addAccount); err != nil {
+				return err
+			}
 		}
 
 		if err := store.Save(); err != nil {
@@ -120,9 +188,22 @@ same name already exists, it will be imported.`,
 
 		if serviceAccount != "" {
 			logger.Info("  Service Account", "service_account", serviceAccount)
+			if len(delegates) > 0 {
+				logger.Info("  Via Delegates", "delegates", strings.Join(delegates, " → "))
+			}
 		} else if configExists {
 			logger.Info("  No service account set. Use 'gcloud-switcher edit " + configName + "' to add one if needed.")
 		}
+		if account != "" {
+			logger.Info("  Account", "account", account)
+		}
+		if len(tags) > 0 {
+			logger.Info("  Tags", "tags", strings.Join(tags, ", "))
+		}
+		if len(aliases) > 0 {
+			logger.Info("  Aliases", "aliases", strings.Join(aliases, ", "))
+		}
+		logADCLoginSettings("  ", newConfig)
 
 		return nil
 	},
@@ -131,4 +212,15 @@ same name already exists, it will be imported.`,
 func init() {
 	addCmd.Flags().StringVarP(&projectID, "project", "p", "", "GCloud Project ID")
 	addCmd.Flags().StringVarP(&serviceAccount, "service-account", "s", "", "Service Account to impersonate (optional)")
+	addCmd.Flags().StringSliceVar(&addDelegates, "delegate", nil, "Service account impersonated before the service account; repeat in order for a chain")
+	addCmd.Flags().StringVarP(&addAccount, "account", "a", "", "User account to log in with (optional)")
+	addCmd.Flags().BoolVar(&protected, "protected", false, "Mark the configuration as sensitive (e.g. production)")
+	addCmd.Flags().StringSliceVar(&addTags, "tag", nil, "Tag the configuration, e.g. --tag env:prod --tag client:acme")
+	_ = addCmd.RegisterFlagCompletionFunc("tag", GetTagNames) //nolint:errcheck
+	addCmd.Flags().StringVar(&addBrowserMode, "browser-mode", "auto", "How logins obtain consent: auto, browser, no-browser or no-launch-browser")
+	_ = addCmd.RegisterFlagCompletionFunc("browser-mode", cobra.FixedCompletions(browserModeValues, cobra.ShellCompDirectiveNoFileComp)) //nolint:errcheck
+	addCmd.Flags().StringSliceVar(&addAliases, "alias", nil, "Short name accepted wherever the configuration name is, e.g. --alias p")
+	addCmd.Flags().StringSliceVar(&addADCScopes, "adc-scope", nil, "OAuth scope requested for ADC in addition to gcloud's defaults, e.g. https://www.googleapis.com/auth/drive")
+	addCmd.Flags().StringVar(&addClientID, "client-id-file", "", "OAuth client secrets file used for ADC logins instead of gcloud's client")
+	addCmd.Flags().BoolVar(&addVerify, "verify", false, "Check with gcloud that the project is accessible and the service account can be impersonated")
 }
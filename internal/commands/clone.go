@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloneProjectID        string
+	cloneServiceAccount   string
+	cloneProperties       []string
+	cloneShareCredentials bool
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <src> <dst>",
+	Short: "Create a new GCloud configuration from an existing one",
+	Long: `Copy a configuration and all the properties of its native gcloud configuration
+under a new name, applying the given overrides.
+
+With --share-credentials, the saved ADC of the source configuration is copied too
+when both configurations use the same account and service account, so no new
+login is required.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// Only the source name can be completed
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetConfigNames(cmd, args, toComplete)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		srcName, dstName := args[0], args[1]
+
+		overrides, err := parseProperties(cloneProperties)
+		if err != nil {
+			return err
+		}
+
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		src, err := resolveConfig(store, srcName)
+		if err != nil {
+			return err
+		}
+		srcName = src.Name
+		if store.NameInUse(dstName, "") {
+			return fmt.Errorf("configuration or alias '%s' already exists", dstName)
+		}
+		if gcloud.ConfigurationExists(ctx, dstName) {
+			return fmt.Errorf("a native gcloud configuration named '%s' already exists", dstName)
+		}
+
+		dst := config.GCloudConfig{
+			Name:           dstName,
+			ProjectID:      src.ProjectID,
+			ServiceAccount: src.ServiceAccount,
+			Delegates:      slices.Clone(src.Delegates),
+			Account:        src.Account,
+			Tags:           slices.Clone(src.Tags),
+			BrowserMode:    src.BrowserMode,
+			ADCScopes:      slices.Clone(src.ADCScopes),
+			ClientIDFile:   src.ClientIDFile,
+			// Aliases stay unique to the source
+		}
+		if cloneProjectID != "" {
+			dst.ProjectID = cloneProjectID
+		}
+		if cmd.Flags().Changed("service-account") {
+			dst.ServiceAccount = cloneServiceAccount
+			if dst.ServiceAccount == "" {
+				dst.Delegates = nil
+			}
+		}
+
+		// Start from the native properties of the source and apply the overrides on top
+		properties := map[string]string{}
+		if gcloud.ConfigurationExists(ctx, srcName) {
+			properties, err = gcloud.GetConfigurationProperties(ctx, srcName)
+			if err != nil {
+				return err
+			}
+		}
+		srcAccount := properties["core/account"]
+		for property, value := range overrides {
+			properties[property] = value
+		}
+		if project, ok := overrides["core/project"]; ok && cloneProjectID == "" {
+			dst.ProjectID = project
+		}
+		if account, ok := overrides["core/account"]; ok {
+			dst.Account = account
+		}
+		properties["core/project"] = dst.ProjectID
+
+		logger.Info("Cloning configuration", "from", srcName, "to", dstName, "project_id", dst.ProjectID)
+
+		steps := []step{{
+			Name: "create native configuration " + dstName,
+			Do:   func(ctx context.Context) error { return gcloud.CopyConfiguration(ctx, dstName, properties) },
+			Undo: func(ctx context.Context) error { return gcloud.DeleteConfiguration(ctx, dstName) },
+		}}
+
+		if cloneShareCredentials {
+			switch {
+			case properties["core/account"] != srcAccount:
+				logger.Warning("Not sharing credentials: the account differs from the source configuration")
+			case dst.ImpersonationChain() != src.ImpersonationChain():
+				logger.Warning("Not sharing credentials: the service account differs from the source configuration")
+			case src.ADCPath == "":
+				logger.Warning("Not sharing credentials: the source configuration has no saved ADC")
+			case config.IsSharedADC(src.ADCPath):
+				dst.ADCPath = src.ADCPath
+			default:
+				adcPath, err := config.GetADCFileForConfig(dstName)
+				if err != nil {
+					return err
+				}
+				steps = append(steps, step{
+					Name: "copy saved ADC",
+					Do: func(ctx context.Context) error {
+						if err := config.CopyFile(src.ADCPath, adcPath); err != nil {
+							return err
+						}
+						dst.ADCPath = adcPath
+						return nil
+					},
+					Undo: func(ctx context.Context) error { return config.RemoveFile(adcPath) },
+				})
+			}
+		}
+
+		steps = append(steps, step{
+			Name: "update configuration store",
+			Do: func(ctx context.Context) error {
+				if err := store.AddConfig(dst); err != nil {
+					return err
+				}
+				store.MarkOwned(dstName)
+				return store.Save()
+			},
+		})
+
+		if err := runSteps(ctx, steps); err != nil {
+			return fmt.Errorf("failed to clone configuration: %w", err)
+		}
+
+		logger.Success("Successfully cloned configuration", "from", srcName, "to", dstName, "project_id", dst.ProjectID)
+		if dst.ServiceAccount != "" {
+			logger.Info("  Service Account", "service_account", formatChain(dst.ServiceAccount, dst.Delegates))
+		}
+		if dst.ADCPath != "" {
+			logger.Info("  Credentials shared with " + srcName + ", no login required")
+		}
+		return nil
+	},
+}
+
+func init() {
+	cloneCmd.Flags().StringVarP(&cloneProjectID, "project", "p", "", "GCloud Project ID for the new configuration")
+	cloneCmd.Flags().StringVarP(&cloneServiceAccount, "service-account", "s", "", "Service Account to impersonate in the new configuration")
+	cloneCmd.Flags().StringArrayVar(&cloneProperties, "property", nil, "Native gcloud property to override, as section/name=value (repeatable)")
+	cloneCmd.Flags().BoolVar(&cloneShareCredentials, "share-credentials", false, "Reuse the saved ADC of the source when the account is the same")
+}
+
+// parseProperties parses section/name=value pairs; properties without a section belong to core
+func parseProperties(values []string) (map[string]string, error) {
+	properties := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid property '%s', expected section/name=value", value)
+		}
+		if !strings.Contains(key, "/") {
+			key = "core/" + key
+		}
+		properties[key] = val
+	}
+	return properties, nil
+}
@@ -2,10 +2,23 @@ package commands
 
 import (
 	"bytes"
-	"io"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/gcloud/gcloudtest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -20,18 +33,23 @@ func executeCommand(root *cobra.Command, args ...string) (output string, err err
 	return buf.String(), err
 }
 
-func captureStdout(f func()) string {
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	f()
+// executeCommandSplit runs a command capturing its standard output and error separately
+func executeCommandSplit(root *cobra.Command, args ...string) (stdout, stderr *bytes.Buffer, err error) {
+	stdout, stderr = new(bytes.Buffer), new(bytes.Buffer)
+	root.SetOut(stdout)
+	root.SetErr(stderr)
+	root.SetArgs(args)
 
-	_ = w.Close() //nolint:errcheck
-	os.Stdout = old
+	err = root.Execute()
+	return stdout, stderr, err
+}
 
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r) //nolint:errcheck
+// runVersion runs the version command with its output captured in a buffer
+func runVersion() string {
+	buf := new(bytes.Buffer)
+	versionCmd.SetOut(buf)
+	defer versionCmd.SetOut(nil)
+	versionCmd.Run(versionCmd, []string{})
 	return buf.String()
 }
 
@@ -57,9 +75,7 @@ func TestVersionCommand(t *testing.T) {
 	Date = "2025-10-20"
 	BuiltBy = "test"
 
-	output := captureStdout(func() {
-		versionCmd.Run(versionCmd, []string{})
-	})
+	output := runVersion()
 
 	if !strings.Contains(output, "1.0.0-test") {
 		t.Errorf("Expected output to contain version '1.0.0-test', got: %s", output)
@@ -79,9 +95,7 @@ func TestVersionCommandDefaults(t *testing.T) {
 	Date = "unknown"
 	BuiltBy = "unknown"
 
-	output := captureStdout(func() {
-		versionCmd.Run(versionCmd, []string{})
-	})
+	output := runVersion()
 
 	if !strings.Contains(output, "dev") {
 		t.Errorf("Expected output to contain version 'dev', got: %s", output)
@@ -99,7 +113,7 @@ func TestCommandsExist(t *testing.T) {
 		commandNames[cmd.Name()] = true
 	}
 
-	expectedCommands := []string{"list", "switch", "add", "edit", "remove", "current", "version", "completion"}
+	expectedCommands := []string{"list", "switch", "add", "edit", "remove", "current", "version", "completion", "doctor", "prune", "rename", "clone", "logout", "prompt", "sync", "status", "refresh", "metadata-server", "token", "adc"}
 
 	for _, expected := range expectedCommands {
 		if !commandNames[expected] {
@@ -204,3 +218,1521 @@ func TestEditCommandArgs(t *testing.T) {
 		t.Errorf("Expected no error when edit command called with 1 argument, got: %v", err)
 	}
 }
+
+func TestDoctorCheckADCFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	adcDir, err := config.GetADCStoragePath()
+	if err != nil {
+		t.Fatalf("Failed to get ADC storage path: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(adcDir, "orphan.json"), []byte("{}"), 0600); err != nil {
+		t.Fatalf("Failed to write orphan ADC file: %v", err)
+	}
+
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", ADCPath: filepath.Join(adcDir, "dev.json")},
+		},
+	}
+
+	results := checkADCFiles(store)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results (missing and orphaned), got %d", len(results))
+	}
+	if results[0].Status != statusWarn || results[0].Fix == nil {
+		t.Errorf("Expected missing ADC file to be a fixable warning, got: %+v", results[0])
+	}
+	if !strings.Contains(results[1].Message, "orphan.json") {
+		t.Errorf("Expected orphaned file to be reported, got: %s", results[1].Message)
+	}
+
+	if err := results[0].Fix(); err != nil {
+		t.Fatalf("Fix failed: %v", err)
+	}
+	if store.Configurations[0].ADCPath != "" {
+		t.Errorf("Expected ADCPath to be cleared, got '%s'", store.Configurations[0].ADCPath)
+	}
+}
+
+func TestDoctorCheckPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not checked on Windows")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	adcFile := filepath.Join(t.TempDir(), "dev.json")
+	if err := os.WriteFile(adcFile, []byte("{}"), 0644); err != nil { //nolint:gosec
+		t.Fatalf("Failed to write ADC file: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{{Name: "dev", ProjectID: "dev-project", ADCPath: adcFile}},
+	}
+
+	results := checkPermissions(store)
+	if len(results) != 1 || results[0].Status != statusWarn {
+		t.Fatalf("Expected a permission warning, got: %+v", results)
+	}
+	if err := results[0].Fix(); err != nil {
+		t.Fatalf("Fix failed: %v", err)
+	}
+	info, err := os.Stat(adcFile)
+	if err != nil {
+		t.Fatalf("Failed to stat ADC file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 after fix, got %v", info.Mode().Perm())
+	}
+}
+
+func TestFindADCCandidates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	adcDir, err := config.GetADCStoragePath()
+	if err != nil {
+		t.Fatalf("Failed to get ADC storage path: %v", err)
+	}
+	for _, name := range []string{"dev", "prod", "removed"} {
+		if err := os.WriteFile(filepath.Join(adcDir, name+".json"), []byte("{}"), 0600); err != nil {
+			t.Fatalf("Failed to write ADC file: %v", err)
+		}
+	}
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(adcDir, "prod.json"), old, old); err != nil {
+		t.Fatalf("Failed to age ADC file: %v", err)
+	}
+
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project"},
+			{Name: "prod", ProjectID: "prod-project"},
+		},
+	}
+
+	candidates, err := findADCCandidates(store, 0, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Name != "removed" || candidates[0].Kind != pruneOrphanedADC {
+		t.Errorf("Expected only the orphaned 'removed' file, got: %+v", candidates)
+	}
+
+	candidates, err = findADCCandidates(store, 30, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("Expected orphaned and stale files, got: %+v", candidates)
+	}
+	for _, candidate := range candidates {
+		if candidate.Name == "prod" && candidate.Kind != pruneStaleADC {
+			t.Errorf("Expected 'prod' to be stale, got: %+v", candidate)
+		}
+		if candidate.Name == "dev" {
+			t.Errorf("Did not expect the fresh 'dev' file to be pruned")
+		}
+	}
+}
+
+func TestRunStepsRollsBack(t *testing.T) {
+	var calls []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	err := runSteps(context.Background(), []step{
+		{Name: "first", Do: record("do first"), Undo: record("undo first")},
+		{Name: "second", Do: record("do second")},
+		{Name: "third", Do: func(context.Context) error { return errors.New("boom") }, Undo: record("undo third")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "third") {
+		t.Fatalf("Expected error mentioning the failing step, got: %v", err)
+	}
+
+	expected := []string{"do first", "do second", "undo first"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+	if errors.Is(err, errRollbackIncomplete) {
+		t.Errorf("Expected a complete rollback, got: %v", err)
+	}
+
+	err = runSteps(context.Background(), []step{
+		{Name: "first", Do: record("do first"), Undo: func(context.Context) error { return errors.New("stuck") }},
+		{Name: "second", Do: func(context.Context) error { return errors.New("boom") }},
+	})
+	if !errors.Is(err, errRollbackIncomplete) || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Expected the failed undo to be reported, got: %v", err)
+	}
+}
+
+func TestRunStepsRollsBackOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var undoErr error
+	err := runSteps(ctx, []step{
+		{
+			Name: "first",
+			Do: func(context.Context) error {
+				cancel()
+				return nil
+			},
+			Undo: func(ctx context.Context) error {
+				undoErr = ctx.Err()
+				return nil
+			},
+		},
+		{Name: "second", Do: func(context.Context) error {
+			t.Error("Expected no step to run after cancellation")
+			return nil
+		}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation error, got: %v", err)
+	}
+	if undoErr != nil {
+		t.Errorf("Expected the rollback to run with a live context, got: %v", undoErr)
+	}
+}
+
+func TestRenameCommandArgs(t *testing.T) {
+	if err := renameCmd.Args(renameCmd, []string{"old"}); err == nil {
+		t.Error("Expected error when rename command called with 1 argument")
+	}
+	if err := renameCmd.Args(renameCmd, []string{"old", "new"}); err != nil {
+		t.Errorf("Expected no error when rename command called with 2 arguments, got: %v", err)
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	properties, err := parseProperties([]string{"compute/region=europe-west1", "account=me@example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if properties["compute/region"] != "europe-west1" {
+		t.Errorf("Expected compute/region to be 'europe-west1', got '%s'", properties["compute/region"])
+	}
+	if properties["core/account"] != "me@example.com" {
+		t.Errorf("Expected properties without section to belong to core, got %v", properties)
+	}
+
+	if _, err := parseProperties([]string{"compute/region"}); err == nil {
+		t.Error("Expected error for a property without value")
+	}
+}
+
+func TestCloneCommandFlags(t *testing.T) {
+	for _, name := range []string{"project", "service-account", "property", "share-credentials"} {
+		if cloneCmd.Flags().Lookup(name) == nil {
+			t.Errorf("Expected '%s' flag to exist on clone command", name)
+		}
+	}
+}
+
+func TestCloneCopiesConfigurationSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	src := config.GCloudConfig{
+		Name:        "prod",
+		ProjectID:   "prod-project",
+		Account:     "jane@example.com",
+		Tags:        []string{"env:prod", "client:acme"},
+		BrowserMode: "no-browser",
+		Aliases:     []string{"p"},
+	}
+	store := &config.ConfigStore{Configurations: []config.GCloudConfig{src}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	gcloudtest.New().
+		On("config configurations describe prod --format=json", `{"properties": {"core": {"account": "jane@example.com"}}}`, nil).
+		On("config configurations describe staging", "", errors.New("not found")).
+		Install(t)
+
+	if _, err := executeCommand(rootCmd, "clone", "prod", "staging"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	staging, err := loaded.FindConfig("staging")
+	if err != nil {
+		t.Fatalf("Expected the clone to be saved: %v", err)
+	}
+	if staging.Account != src.Account || !slices.Equal(staging.Tags, src.Tags) || staging.BrowserMode != src.BrowserMode {
+		t.Errorf("Expected the account, tags and browser mode to carry over, got: %+v", staging)
+	}
+	if len(staging.Aliases) > 0 {
+		t.Errorf("Expected the aliases not to be copied, got: %v", staging.Aliases)
+	}
+}
+
+func TestLogoutCommandArgs(t *testing.T) {
+	defer func() { logoutAll = false }()
+
+	if err := logoutCmd.Args(logoutCmd, []string{}); err == nil {
+		t.Error("Expected error when logout command called without name or --all")
+	}
+	if err := logoutCmd.Args(logoutCmd, []string{"config1"}); err != nil {
+		t.Errorf("Expected no error when logout command called with 1 argument, got: %v", err)
+	}
+
+	logoutAll = true
+	if err := logoutCmd.Args(logoutCmd, []string{}); err != nil {
+		t.Errorf("Expected no error when logout command called with --all, got: %v", err)
+	}
+	if err := logoutCmd.Args(logoutCmd, []string{"config1"}); err == nil {
+		t.Error("Expected error when logout command combines a name with --all")
+	}
+}
+
+func TestRemoveCommandFlags(t *testing.T) {
+	if removeCmd.Flags().Lookup("purge") == nil {
+		t.Error("Expected 'purge' flag to exist on remove command")
+	}
+	if removeCmd.Flags().Lookup("yes") == nil {
+		t.Error("Expected 'yes' flag to exist on remove command")
+	}
+}
+
+func TestPromptCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gcloudDir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+
+	if err := os.WriteFile(filepath.Join(gcloudDir, "active_config"), []byte("prod\n"), 0600); err != nil {
+		t.Fatalf("Failed to write active_config: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{{Name: "prod", ProjectID: "prod-project", Protected: true}},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.RecordCredentials("prod", false, time.Now(), time.Time{})
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	output, err := executeCommand(rootCmd, "prompt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(output) != "prod:prod-project ! ⟳" {
+		t.Errorf("Unexpected prompt segment: %q", output)
+	}
+
+	output, err = executeCommand(rootCmd, "prompt", "--format", "{{.project}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(output) != "prod-project" {
+		t.Errorf("Unexpected custom prompt segment: %q", output)
+	}
+	promptFormat = defaultPromptFormat
+
+	// Credentials that were valid go stale once their token expires
+	tests := []struct {
+		expiresAt time.Time
+		expected  string
+	}{
+		{time.Now().Add(time.Hour), "prod:prod-project !"},
+		{time.Now().Add(-time.Minute), "prod:prod-project ! ⟳"},
+	}
+	for _, tt := range tests {
+		state.RecordCredentials("prod", true, time.Now().Add(-time.Hour), tt.expiresAt)
+		if err := state.Save(); err != nil {
+			t.Fatalf("Failed to save state: %v", err)
+		}
+		output, err := executeCommand(rootCmd, "prompt")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.TrimSpace(output) != tt.expected {
+			t.Errorf("Expected %q for a token expiring at %v, got %q", tt.expected, tt.expiresAt, output)
+		}
+	}
+}
+
+func TestValidateCredentialsUsesFreshCache(t *testing.T) {
+	state := &config.State{Credentials: map[string]config.CredentialState{
+		"dev": {Valid: true, CheckedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	accountValid, adcValid, cached := validateCredentials(context.Background(), state, "dev", false)
+	if !cached || !accountValid || !adcValid {
+		t.Errorf("Expected a fresh cached check to be trusted, got account=%v adc=%v cached=%v", accountValid, adcValid, cached)
+	}
+}
+
+// barrierRunner fails every invocation, but only once the expected number of
+// invocations are running at the same time
+type barrierRunner struct {
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (r *barrierRunner) Run(ctx context.Context, inv gcloud.Invocation) ([]byte, error) {
+	r.arrived <- struct{}{}
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("not logged in")
+}
+
+func TestValidateCredentialsChecksConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := &barrierRunner{arrived: make(chan struct{}), release: make(chan struct{})}
+	previous := gcloud.SetRunner(fake)
+	defer gcloud.SetRunner(previous)
+
+	go func() {
+		// Both checks must be in flight before either is allowed to finish
+		for range 2 {
+			select {
+			case <-fake.arrived:
+			case <-time.After(5 * time.Second):
+				return
+			}
+		}
+		close(fake.release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	state := config.LoadState()
+	accountValid, adcValid, cached := validateCredentials(ctx, state, "dev", true)
+	if accountValid || adcValid || cached {
+		t.Errorf("Expected failed uncached checks, got account=%v adc=%v cached=%v", accountValid, adcValid, cached)
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected the checks to run concurrently instead of waiting for the timeout")
+	}
+}
+
+func TestSwitchRollsBackOnFailure(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gcloudDir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+
+	liveADC := filepath.Join(gcloudDir, "application_default_credentials.json")
+	if err := os.WriteFile(liveADC, []byte("dev-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+	prodADC := filepath.Join(t.TempDir(), "prod.json")
+	if err := os.WriteFile(prodADC, []byte("prod-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write saved ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		ActiveConfig: "dev",
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project"},
+			{Name: "prod", ProjectID: "prod-project", ADCPath: prodADC},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	fake := gcloudtest.New().
+		On("config configurations list", "dev\n", nil).
+		On("config configurations describe prod --format=value", "old-project\n", nil).
+		On("auth print-access-token", `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`, nil).
+		On("auth application-default print-access-token", `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`, nil).
+		On("config set project", "", errors.New("permission denied")).
+		Install(t)
+
+	_, err := executeCommand(rootCmd, "switch", "prod")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("Expected the switch to fail, got: %v", err)
+	}
+
+	expected := []string{
+		"config configurations activate prod",
+		"config set project prod-project --quiet",
+		"config configurations activate dev",
+	}
+	if strings.Join(fake.Mutations(), ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected mutations %v, got %v", expected, fake.Mutations())
+	}
+	if data, _ := os.ReadFile(liveADC); string(data) != "dev-adc" { //nolint:gosec
+		t.Errorf("Expected the previous ADC to be restored, got '%s'", data)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if loaded.ActiveConfig != "dev" {
+		t.Errorf("Expected active configuration to stay 'dev', got '%s'", loaded.ActiveConfig)
+	}
+}
+
+func TestSwitchRejectsWrongAccount(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "prod", ProjectID: "prod-project", Account: "jane@example.com", BrowserMode: string(gcloud.BrowserNone)},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	fake := gcloudtest.New().
+		On("config configurations list", "prod\n", nil).
+		On("auth print-access-token", "", errors.New("reauthentication required")).
+		On("auth application-default print-access-token", "", errors.New("reauthentication required")).
+		On("config get-value account", "other@example.com\n", nil).
+		Install(t)
+
+	_, err := executeCommand(rootCmd, "switch", "prod")
+	if err == nil || !strings.Contains(err.Error(), "logged in as 'other@example.com' instead of 'jane@example.com'") {
+		t.Fatalf("Expected the wrong account to be rejected, got: %v", err)
+	}
+
+	logins := 0
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth login jane@example.com") {
+			logins++
+		}
+	}
+	if logins != maxLoginAttempts {
+		t.Errorf("Expected %d logins hinting the account, got: %v", maxLoginAttempts, fake.Invocations())
+	}
+	if !slices.Contains(fake.Mutations(), "config set core/account jane@example.com --configuration prod --quiet") {
+		t.Errorf("Expected the account to be bound to the configuration, got: %v", fake.Mutations())
+	}
+}
+
+func TestSwitchReusesCredentialsOfSameAccount(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gcloudDir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+
+	key := config.CredentialKey("jane@example.com", "")
+	sharedADC, err := config.GetADCFileForCredential(key)
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	if err := os.WriteFile(sharedADC, []byte("jane-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	// 'dev' refreshed the credentials of the account, 'staging' still has its own older copy
+	legacyADC, err := config.GetADCFileForConfig("staging")
+	if err != nil {
+		t.Fatalf("Failed to get ADC path: %v", err)
+	}
+	if err := os.WriteFile(legacyADC, []byte("old-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com", ADCPath: sharedADC},
+			{Name: "staging", ProjectID: "staging-project", Account: "jane@example.com", ADCPath: legacyADC},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.BindIdentity("dev", key)
+	state.RecordCredentials(key, true, time.Now(), time.Now().Add(time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	fake := gcloudtest.New().
+		On("config configurations list", "dev\nstaging\n", nil).
+		On("config configurations describe staging --format=value(properties.core.account)", "jane@example.com\n", nil).
+		Install(t)
+
+	if _, err := executeCommand(rootCmd, "switch", "staging"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth ") {
+			t.Errorf("Expected the shared credentials to be reused, got: %s", inv)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(gcloudDir, "application_default_credentials.json")); string(data) != "jane-adc" { //nolint:gosec
+		t.Errorf("Expected the shared ADC to be restored, got '%s'", data)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if staging, _ := loaded.FindConfig("staging"); staging.ADCPath != sharedADC {
+		t.Errorf("Expected 'staging' to migrate to the shared ADC, got '%s'", staging.ADCPath)
+	}
+}
+
+func TestSwitchLogsInAfterServiceAccountEdit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { editServiceAccount = "" }()
+	deployer := "deployer@prod-project.iam.gserviceaccount.com"
+	auditor := "auditor@prod-project.iam.gserviceaccount.com"
+
+	key := config.CredentialKey("jane@example.com", deployer)
+	sharedADC, err := config.GetADCFileForCredential(key)
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	if err := os.WriteFile(sharedADC, []byte("deployer-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "prod", ProjectID: "prod-project", Account: "jane@example.com", ServiceAccount: deployer, ADCPath: sharedADC},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.BindIdentity("prod", key)
+	state.RecordCredentials(key, true, time.Now(), time.Now().Add(time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	// Every token is valid, only the identity of the credentials changed
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("config configurations list", "prod\n", nil).
+		On("config get-value account", "jane@example.com\n", nil).
+		On("auth print-access-token", token, nil).
+		On("auth application-default print-access-token", token, nil).
+		Install(t)
+
+	if _, err := executeCommand(rootCmd, "edit", "prod", "--service-account", auditor); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := executeCommand(rootCmd, "switch", "prod"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var logins []string
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth application-default login") {
+			logins = append(logins, inv)
+		}
+	}
+	if len(logins) != 1 || !strings.Contains(logins[0], auditor) {
+		t.Errorf("Expected a login impersonating the new service account, got: %v", logins)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if prod, _ := loaded.FindConfig("prod"); prod.ADCPath == sharedADC {
+		t.Errorf("Expected the ADC of the previous service account to be dropped, got '%s'", prod.ADCPath)
+	}
+}
+
+func TestSwitchLogsInAgainForNewScopes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	drive := "https://www.googleapis.com/auth/drive"
+
+	key := config.CredentialKey("jane@example.com", "")
+	sharedADC, err := config.GetADCFileForCredential(key)
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	if err := os.WriteFile(sharedADC, []byte("jane-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	// The saved credentials are valid but were obtained without the Drive scope
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "bq", ProjectID: "bq-project", Account: "jane@example.com", ADCPath: sharedADC, ADCScopes: []string{drive}},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.BindIdentity("bq", key)
+	state.RecordCredentials(key, true, time.Now(), time.Now().Add(time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("config configurations list", "bq\n", nil).
+		On("config get-value account", "jane@example.com\n", nil).
+		On("auth print-access-token", token, nil).
+		On("auth application-default print-access-token", token, nil).
+		Install(t)
+
+	// Once logged in with the scope, the credentials are reused again
+	for range 2 {
+		if _, err := executeCommand(rootCmd, "switch", "bq"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	var logins []string
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth application-default login") {
+			logins = append(logins, inv)
+		}
+	}
+	if len(logins) != 1 || !strings.Contains(logins[0], ","+drive) {
+		t.Errorf("Expected a single ADC login requesting the Drive scope, got: %v", logins)
+	}
+	scopedKey := store.Configurations[0].CredentialKey("jane@example.com")
+	if credentials := config.LoadState().Credentials[scopedKey]; !credentials.Covers([]string{drive}, "") {
+		t.Errorf("Expected the scopes of the login to be recorded, got: %+v", credentials)
+	}
+}
+
+func TestSwitchTrustsScopedADCWithoutState(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	drive := "https://www.googleapis.com/auth/drive"
+
+	// state.json was deleted after 'bq' logged in with the Drive scope
+	cfg := config.GCloudConfig{Name: "bq", ProjectID: "bq-project", Account: "jane@example.com", ADCScopes: []string{drive}}
+	scopedADC, err := config.GetADCFileForCredential(cfg.CredentialKey("jane@example.com"))
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	if err := os.WriteFile(scopedADC, []byte("drive-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	cfg.ADCPath = scopedADC
+	store := &config.ConfigStore{Configurations: []config.GCloudConfig{cfg}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("config configurations list", "bq\n", nil).
+		On("config get-value account", "jane@example.com\n", nil).
+		On("auth print-access-token", token, nil).
+		On("auth application-default print-access-token", token, nil).
+		Install(t)
+
+	for range 2 {
+		if _, err := executeCommand(rootCmd, "switch", "bq"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth login") || strings.HasPrefix(inv, "auth application-default login") {
+			t.Errorf("Expected the saved ADC to be checked instead of logging in, got: %s", inv)
+		}
+	}
+}
+
+func TestSwitchKeepsCredentialsPerADCSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	clientIDFile := filepath.Join(t.TempDir(), "client.json")
+
+	key := config.CredentialKey("jane@example.com", "")
+	sharedADC, err := config.GetADCFileForCredential(key)
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	if err := os.WriteFile(sharedADC, []byte("jane-adc"), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	// 'sheets' uses the account of 'dev' with its own OAuth client
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com", ADCPath: sharedADC},
+			{Name: "sheets", ProjectID: "sheets-project", Account: "jane@example.com", ClientIDFile: clientIDFile},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.BindIdentity("dev", key)
+	state.RecordCredentials(key, true, time.Now(), time.Now().Add(time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("config configurations list", "dev\nsheets\n", nil).
+		On("config get-value account", "jane@example.com\n", nil).
+		On("auth print-access-token", token, nil).
+		On("auth application-default print-access-token", token, nil).
+		Install(t)
+
+	for _, name := range []string{"dev", "sheets", "dev", "sheets"} {
+		if _, err := executeCommand(rootCmd, "switch", name); err != nil {
+			t.Fatalf("Unexpected error switching to '%s': %v", name, err)
+		}
+	}
+
+	var logins []string
+	for _, inv := range fake.Invocations() {
+		if strings.HasPrefix(inv, "auth application-default login") {
+			logins = append(logins, inv)
+		}
+	}
+	if len(logins) != 1 {
+		t.Errorf("Expected a single login for the other OAuth client, got: %v", logins)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	dev, _ := loaded.FindConfig("dev")
+	sheets, _ := loaded.FindConfig("sheets")
+	if dev.ADCPath != sharedADC || sheets.ADCPath == sharedADC || sheets.ADCPath == "" {
+		t.Errorf("Expected separate ADC files, got '%s' and '%s'", dev.ADCPath, sheets.ADCPath)
+	}
+	if data, _ := os.ReadFile(sharedADC); string(data) != "jane-adc" { //nolint:gosec
+		t.Errorf("Expected the ADC of 'dev' to be kept, got '%s'", data)
+	}
+}
+
+func TestSyncReconcilesDrift(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { syncFrom, outputFlag = syncFromStore, "" }()
+
+	store := &config.ConfigStore{
+		ActiveConfig: "dev",
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com"},
+			{Name: "prod", ProjectID: "prod-project"},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	// Someone ran 'gcloud config set project' and 'gcloud config set account'
+	fake := gcloudtest.New().
+		On("config configurations list", "dev\n", nil).
+		On("config configurations describe dev --format=json", `{"properties": {"core": {"project": "scratch-project", "account": "jane@example.com"}, "auth": {"impersonate_service_account": "sa@p.iam.gserviceaccount.com"}}}`, nil).
+		Install(t)
+
+	out, err := executeCommand(rootCmd, "current", "-o", "template={{range .drift}}{{.field}}={{.gcloud}};{{end}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out != "project=scratch-project;impersonated service account=sa@p.iam.gserviceaccount.com;\n" {
+		t.Errorf("Unexpected drift: %q", out)
+	}
+	outputFlag = ""
+
+	if _, err := executeCommand(rootCmd, "sync"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{
+		"config set core/project dev-project --configuration dev --quiet",
+		"config unset auth/impersonate_service_account --configuration dev --quiet",
+	}
+	if strings.Join(fake.Mutations(), ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected mutations %v, got %v", expected, fake.Mutations())
+	}
+
+	if _, err := executeCommand(rootCmd, "sync", "--from", "gcloud"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if dev, _ := loaded.FindConfig("dev"); dev.ProjectID != "scratch-project" || dev.ServiceAccount != "sa@p.iam.gserviceaccount.com" {
+		t.Errorf("Expected the gcloud values to be adopted, got %+v", dev)
+	}
+
+	if _, err := executeCommand(rootCmd, "sync", "--from", "nowhere"); err == nil {
+		t.Error("Expected an unknown --from value to be rejected")
+	}
+}
+
+func TestSyncKeepsServiceAccountForUserADC(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gcloudDir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+	defer func() { syncFrom = syncFromStore }()
+	deployer := "deployer@prod-project.iam.gserviceaccount.com"
+
+	store := &config.ConfigStore{
+		ActiveConfig:   "prod",
+		Configurations: []config.GCloudConfig{{Name: "prod", ProjectID: "prod-project", Account: "jane@example.com", ServiceAccount: deployer}},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	// The live ADC comes from a login without impersonation
+	userADC := `{"type": "authorized_user", "account": "jane@example.com"}`
+	if err := os.WriteFile(filepath.Join(gcloudDir, "application_default_credentials.json"), []byte(userADC), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+	gcloudtest.New().
+		On("config configurations list", "prod\n", nil).
+		On("config configurations describe prod --format=json", `{"properties": {"core": {"project": "prod-project", "account": "jane@example.com"}}}`, nil).
+		Install(t)
+
+	out, err := executeCommand(rootCmd, "sync", "--from", "gcloud")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "Cannot be adopted") {
+		t.Errorf("Expected the ADC drift to be reported, got: %s", out)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if prod, _ := loaded.FindConfig("prod"); prod.ServiceAccount != deployer {
+		t.Errorf("Expected the service account to be kept, got '%s'", prod.ServiceAccount)
+	}
+}
+
+func TestStatusReportsEveryConfiguration(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { outputFlag = "" }()
+
+	savedADC := filepath.Join(t.TempDir(), "dev.json")
+	if err := os.WriteFile(savedADC, []byte(`{"type": "authorized_user", "refresh_token": "x"}`), 0600); err != nil {
+		t.Fatalf("Failed to write saved ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com", ADCPath: savedADC},
+			{Name: "prod", ProjectID: "prod-project", Account: "ops@example.com"},
+			{Name: "cached", ProjectID: "cached-project"},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	state := config.LoadState()
+	state.RecordCredentials("cached", true, time.Now(), time.Now().Add(time.Hour))
+	state.RecordUse("dev", time.Now().Add(-time.Hour))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("auth print-access-token --configuration dev", token, nil).
+		On("auth print-access-token --configuration prod", "", errors.New("reauthentication required")).
+		On("auth application-default print-access-token", token, nil).
+		Install(t)
+
+	out, err := executeCommand(rootCmd, "status", "-o", "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var statuses []map[string]any
+	if err := json.Unmarshal([]byte(out), &statuses); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 configurations, got: %s", out)
+	}
+	dev, prod, cached := statuses[0], statuses[1], statuses[2]
+	if dev["needs_login"] != false || dev["credential_type"] != "user" || dev["last_used"] == nil {
+		t.Errorf("Unexpected status for 'dev': %v", dev)
+	}
+	if prod["needs_login"] != true || prod["account_valid"] != false || prod["credential_type"] != "none" {
+		t.Errorf("Unexpected status for 'prod': %v", prod)
+	}
+	if cached["needs_login"] != false || cached["adc_valid"] != true {
+		t.Errorf("Unexpected status for 'cached': %v", cached)
+	}
+	for _, inv := range fake.Invocations() {
+		if strings.Contains(inv, "--configuration cached") || strings.HasPrefix(inv, "config configurations activate") {
+			t.Errorf("Unexpected invocation: %s", inv)
+		}
+	}
+}
+
+// countingRunner records the largest number of invocations running at the same time
+type countingRunner struct {
+	mu        sync.Mutex
+	running   int
+	maxActive int
+}
+
+func (r *countingRunner) Run(ctx context.Context, inv gcloud.Invocation) ([]byte, error) {
+	r.mu.Lock()
+	r.running++
+	r.maxActive = max(r.maxActive, r.running)
+	r.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return nil, errors.New("not logged in")
+}
+
+func TestStatusBoundsConcurrentChecks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := &countingRunner{}
+	previous := gcloud.SetRunner(fake)
+	defer gcloud.SetRunner(previous)
+
+	store := &config.ConfigStore{}
+	var configs []*config.GCloudConfig
+	for i := range 8 {
+		store.Configurations = append(store.Configurations, config.GCloudConfig{Name: fmt.Sprintf("env-%d", i), Account: "jane@example.com"})
+	}
+	for i := range store.Configurations {
+		configs = append(configs, &store.Configurations[i])
+	}
+
+	view := checkConfigStatuses(context.Background(), store, config.LoadState(), configs, 2, true)
+	if len(view) != 8 {
+		t.Fatalf("Expected 8 statuses, got %d", len(view))
+	}
+	// Each configuration runs at most two checks at once
+	if fake.maxActive > 4 || fake.maxActive < 2 {
+		t.Errorf("Expected between 2 and 4 concurrent invocations with 2 workers, got %d", fake.maxActive)
+	}
+}
+
+func TestRefreshLogsInOncePerAccount(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gcloudDir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+	defer func() { refreshAll = false }()
+
+	liveADC := filepath.Join(gcloudDir, "application_default_credentials.json")
+	if err := os.WriteFile(liveADC, []byte(`{"type": "authorized_user"}`), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+
+	store := &config.ConfigStore{
+		ActiveConfig: "dev",
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project"},
+			{Name: "acme-staging", ProjectID: "staging-project", Account: "jane@example.com", BrowserMode: string(gcloud.BrowserNone)},
+			{Name: "acme-prod", ProjectID: "prod-project", Account: "jane@example.com", BrowserMode: string(gcloud.BrowserNone)},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	token := `{"token": "ya29.test", "expiry": "2099-01-01T00:00:00Z"}`
+	fake := gcloudtest.New().
+		On("config configurations list", "dev\nacme-staging\nacme-prod\n", nil).
+		On("config configurations list --filter=is_active:true", "dev\n", nil).
+		On("auth print-access-token --configuration dev", token, nil).
+		On("auth print-access-token --configuration acme", "", errors.New("reauthentication required")).
+		On("auth application-default print-access-token", token, nil).
+		On("config get-value account", "jane@example.com\n", nil).
+		Install(t)
+
+	if _, err := executeCommand(rootCmd, "refresh", "--all"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"config configurations activate acme-staging",
+		"auth login jane@example.com --update-adc --no-browser",
+		"config configurations activate dev",
+	}
+	if strings.Join(fake.Mutations(), ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected mutations %v, got %v", expected, fake.Mutations())
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	staging, _ := loaded.FindConfig("acme-staging")
+	prod, _ := loaded.FindConfig("acme-prod")
+	if !config.IsSharedADC(staging.ADCPath) || prod.ADCPath != staging.ADCPath {
+		t.Errorf("Expected both configurations to use the new shared ADC, got '%s' and '%s'", staging.ADCPath, prod.ADCPath)
+	}
+	if loaded.ActiveConfig != "dev" {
+		t.Errorf("Expected 'dev' to stay active, got '%s'", loaded.ActiveConfig)
+	}
+	if data, _ := os.ReadFile(liveADC); string(data) != `{"type": "authorized_user"}` { //nolint:gosec
+		t.Errorf("Expected the ADC of 'dev' to be put back, got '%s'", data)
+	}
+
+	if _, err := executeCommand(rootCmd, "refresh", "dev", "--all"); err == nil {
+		t.Error("Expected a name and --all to be rejected together")
+	}
+}
+
+func TestVerifyConfigValuesExplainsMissingRoles(t *testing.T) {
+	fake := gcloudtest.New().
+		On("iam service-accounts describe", "deployer@my-project.iam.gserviceaccount.com\n", nil).
+		On("auth print-access-token --impersonate-service-account", "", errors.New("PERMISSION_DENIED: iam.serviceAccounts.getAccessToken")).
+		Install(t)
+
+	err := verifyConfigValues(context.Background(), "my-project", "deployer@my-project.iam.gserviceaccount.com", []string{"broker@shared-project.iam.gserviceaccount.com"})
+	if err == nil || !strings.Contains(err.Error(), "roles/iam.serviceAccountTokenCreator") {
+		t.Fatalf("Expected the missing Token Creator role to be explained, got: %v", err)
+	}
+	chain := "auth print-access-token --impersonate-service-account=broker@shared-project.iam.gserviceaccount.com,deployer@my-project.iam.gserviceaccount.com"
+	if !slices.Contains(fake.Invocations(), chain) {
+		t.Errorf("Expected impersonation through the delegate, got: %v", fake.Invocations())
+	}
+	if strings.Contains(err.Error(), "project 'my-project'") {
+		t.Errorf("Expected the accessible project not to be reported, got: %v", err)
+	}
+}
+
+func TestAddRejectsInvalidValues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := gcloudtest.New().
+		On("config configurations describe", "", errors.New("NOT_FOUND")).
+		Install(t)
+	defer func() { projectID, serviceAccount = "", "" }()
+
+	_, err := executeCommand(rootCmd, "add", "web", "--project", "My_Project", "--service-account", "deployer")
+	if err == nil || !strings.Contains(err.Error(), "invalid project ID") || !strings.Contains(err.Error(), "invalid service account") {
+		t.Fatalf("Expected both values to be rejected, got: %v", err)
+	}
+	if len(fake.Mutations()) != 0 {
+		t.Errorf("Expected no gcloud changes, got: %v", fake.Mutations())
+	}
+}
+
+func saveTaggedStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "acme-prod", ProjectID: "acme-prod-1", Tags: []string{"client:acme", "env:prod"}},
+			{Name: "acme-dev", ProjectID: "acme-dev-1", Tags: []string{"client:acme", "env:dev"}},
+			{Name: "globex-prod", ProjectID: "globex-prod-1", Tags: []string{"client:globex", "env:prod"}},
+			{Name: "scratch", ProjectID: "scratch-1"},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+}
+
+func TestListFiltersByTag(t *testing.T) {
+	saveTaggedStore(t)
+	defer func() { listTags, outputFlag = nil, "" }()
+
+	out, err := executeCommand(rootCmd, "list", "--tag", "env:prod", "-o", "template={{range .}}{{.name}} {{end}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out) != "acme-prod globex-prod" {
+		t.Errorf("Expected only prod configurations, got: %q", out)
+	}
+}
+
+func TestGroupByTag(t *testing.T) {
+	saveTaggedStore(t)
+	store, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+
+	groups, keys := groupByTag(store.SelectByTags(nil), "client")
+	if strings.Join(keys, ",") != "acme,globex," {
+		t.Errorf("Expected sorted groups with untagged last, got: %q", keys)
+	}
+	if len(groups["acme"]) != 2 || groups[""][0].Name != "scratch" {
+		t.Errorf("Unexpected groups: %v", groups)
+	}
+}
+
+func TestGetConfigNamesFiltersByTag(t *testing.T) {
+	saveTaggedStore(t)
+	cmd := &cobra.Command{Use: "test"}
+	var tags []string
+	addTagSelectorFlag(cmd, &tags, "")
+	if err := cmd.Flags().Set("tag", "client:acme"); err != nil {
+		t.Fatalf("Failed to set flag: %v", err)
+	}
+
+	names, _ := GetConfigNames(cmd, nil, "")
+	if len(names) != 2 || names[0] != "acme-prod\tclient:acme, env:prod" {
+		t.Errorf("Expected the acme configurations with their tags, got: %q", names)
+	}
+}
+
+func TestRemoveByTag(t *testing.T) {
+	saveTaggedStore(t)
+	defer func() { removeTags, removeYes = nil, false }()
+
+	if _, err := executeCommand(rootCmd, "remove", "--tag", "client:acme", "--yes"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if len(store.Configurations) != 2 || store.Configurations[0].Name != "globex-prod" {
+		t.Errorf("Expected only the acme configurations to be removed, got: %+v", store.Configurations)
+	}
+
+	if err := removeCmd.Args(removeCmd, []string{"scratch"}); err == nil {
+		t.Error("Expected error when combining a name with --tag")
+	}
+}
+
+func TestCommandsResolveAliasesAndSuggestNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "acme-production-europe", ProjectID: "acme-prod-eu", Aliases: []string{"p"}},
+			{Name: "acme-staging", ProjectID: "acme-staging-1"},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	defer func() { editTags = nil }()
+
+	if _, err := executeCommand(rootCmd, "edit", "p", "--tag", "env:prod"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	if !loaded.Configurations[0].HasTag("env:prod") {
+		t.Errorf("Expected the aliased configuration to be edited, got: %+v", loaded.Configurations[0])
+	}
+
+	_, err = executeCommand(rootCmd, "remove", "acme-stagign")
+	if err == nil || !strings.Contains(err.Error(), "did you mean: acme-staging?") {
+		t.Errorf("Expected a did-you-mean suggestion, got: %v", err)
+	}
+}
+
+func TestLoginOptionsPrecedence(t *testing.T) {
+	for _, key := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY", "WAYLAND_DISPLAY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DISPLAY", ":0")
+	defer func() { noBrowser, noLaunchBrowser = false, false }()
+
+	cfg := &config.GCloudConfig{Name: "remote", BrowserMode: string(gcloud.BrowserManual)}
+	if mode := loginOptions(cfg).Browser; mode != gcloud.BrowserManual {
+		t.Errorf("Expected the configuration's mode, got '%s'", mode)
+	}
+
+	noBrowser = true
+	if mode := loginOptions(cfg).Browser; mode != gcloud.BrowserNone {
+		t.Errorf("Expected the global flag to win, got '%s'", mode)
+	}
+	noBrowser = false
+
+	if mode := loginOptions(&config.GCloudConfig{Name: "local"}).Browser; mode != gcloud.BrowserLaunch {
+		t.Errorf("Expected a browser with a display, got '%s'", mode)
+	}
+	t.Setenv("DISPLAY", "")
+	t.Setenv("SSH_CONNECTION", "10.0.0.1 52000 10.0.0.2 22")
+	if mode := loginOptions(&config.GCloudConfig{Name: "local"}).Browser; mode != gcloud.BrowserManual {
+		t.Errorf("Expected a browser-less login over SSH, got '%s'", mode)
+	}
+
+	if _, err := parseBrowserModeFlag("maybe"); err == nil {
+		t.Error("Expected an invalid --browser-mode to be rejected")
+	}
+}
+
+func TestTokenPrintsWithoutSwitching(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { outputFlag, tokenIdentity, tokenAudience, tokenScopes = "", false, "", nil }()
+
+	savedADC := filepath.Join(t.TempDir(), "prod.json")
+	if err := os.WriteFile(savedADC, []byte(`{"type": "impersonated_service_account"}`), 0600); err != nil {
+		t.Fatalf("Failed to write saved ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		ActiveConfig: "dev",
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com"},
+			{Name: "prod", ProjectID: "prod-project", Account: "jane@example.com", ServiceAccount: "deployer@prod-project.iam.gserviceaccount.com", ADCPath: savedADC, Aliases: []string{"p"}},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	idToken := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp": 4070908800}`)) + ".signature"
+	fake := gcloudtest.New().
+		On("auth application-default print-access-token", `{"token": "ya29.prod", "expiry": "2099-01-01T00:00:00Z"}`, nil).
+		On("auth print-identity-token --configuration prod", idToken, nil).
+		Install(t)
+
+	out, err := executeCommand(rootCmd, "token", "prod", "--scopes", "https://www.googleapis.com/auth/drive", "-o", "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var token map[string]any
+	if err := json.Unmarshal([]byte(out), &token); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if token["token"] != "ya29.prod" || token["type"] != "access_token" || token["expires_at"] != "2099-01-01T00:00:00Z" {
+		t.Errorf("Unexpected access token: %v", token)
+	}
+	if !slices.Contains(fake.Invocations(), "auth application-default print-access-token --format=json --scopes https://www.googleapis.com/auth/drive") {
+		t.Errorf("Expected the scopes to be requested, got: %v", fake.Invocations())
+	}
+
+	outputFlag, tokenScopes = "", nil
+	out, err = executeCommand(rootCmd, "token", "prod", "--id-token", "--audience", "https://api.example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out) != idToken {
+		t.Errorf("Expected the bare identity token, got: %q", out)
+	}
+
+	// Messages such as the alias notice must not end up in $(gcloud-switcher token p)
+	tokenIdentity, tokenAudience = false, ""
+	stdout, stderr, err := executeCommandSplit(rootCmd, "token", "p")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stdout.String() != "ya29.prod\n" {
+		t.Errorf("Expected only the token on stdout, got: %q", stdout)
+	}
+	if !strings.Contains(stderr.String(), "Using configuration 'prod' for 'p'") {
+		t.Errorf("Expected the alias notice on stderr, got: %q", stderr)
+	}
+
+	if _, err := executeCommand(rootCmd, "token", "dev", "--id-token", "--audience", "https://api.example.com"); err == nil {
+		t.Error("Expected an audience to be rejected for a user identity token")
+	}
+	if mutations := fake.Mutations(); len(mutations) > 0 {
+		t.Errorf("Expected no changes to gcloud, got: %v", mutations)
+	}
+}
+
+func TestDelegationChain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { projectID, serviceAccount, addDelegates, outputFlag = "", "", nil, "" }()
+	broker := "broker@shared-project.iam.gserviceaccount.com"
+	deployer := "deployer@prod-project.iam.gserviceaccount.com"
+
+	gcloudtest.New().
+		On("config configurations describe", "", errors.New("NOT_FOUND")).
+		Install(t)
+	if _, err := executeCommand(rootCmd, "add", "prod", "-p", "prod-project", "-s", deployer, "--delegate", broker); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := executeCommand(rootCmd, "add", "dev", "-p", "dev-project", "-s", "", "--delegate", broker); err == nil {
+		t.Error("Expected delegates without a service account to be rejected")
+	}
+
+	out, err := executeCommand(rootCmd, "list", "-o", "template={{range .}}{{.name}}={{range .delegates}}{{.}}>{{end}}{{.service_account}};{{end}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out != "prod="+broker+">"+deployer+";\n" {
+		t.Errorf("Unexpected chain in list: %q", out)
+	}
+
+	// The saved ADC was obtained without the delegate
+	store, err := config.LoadConfigStore()
+	if err != nil {
+		t.Fatalf("Failed to load store: %v", err)
+	}
+	prod, _ := store.FindConfig("prod")
+	prod.ADCPath = filepath.Join(t.TempDir(), "prod.json")
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+	adc := `{"type": "impersonated_service_account", "delegates": %s, "service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/` + deployer + `:generateAccessToken", "source_credentials": {"type": "authorized_user"}}`
+	if err := os.WriteFile(prod.ADCPath, fmt.Appendf(nil, adc, `[]`), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+	outputFlag = "json"
+	out, err = executeCommand(rootCmd, "adc", "inspect", "prod")
+	if err == nil || !strings.Contains(out, "delegates are (none) instead of "+broker) {
+		t.Errorf("Expected the missing delegate to be reported, got %v: %s", err, out)
+	}
+
+	if err := os.WriteFile(prod.ADCPath, fmt.Appendf(nil, adc, `["projects/-/serviceAccounts/`+broker+`"]`), 0600); err != nil {
+		t.Fatalf("Failed to write ADC: %v", err)
+	}
+	out, err = executeCommand(rootCmd, "adc", "inspect", "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var view adcView
+	if err := json.Unmarshal([]byte(out), &view); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if view.Configuration != "prod" || view.ServiceAccount != deployer || len(view.Delegates) != 1 || view.Delegates[0] != broker || len(view.Problems) != 0 {
+		t.Errorf("Unexpected inspection: %+v", view)
+	}
+}
+
+func TestDryRunKeepsStructuredOutputClean(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func() { dryRun, outputFlag = false, ""; dryrun.Reset() }()
+	store := &config.ConfigStore{Configurations: []config.GCloudConfig{{Name: "dev", ProjectID: "dev-project"}}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	stdout, stderr, err := executeCommandSplit(rootCmd, "--dry-run", "list", "-o", "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	printPlan(stderr)
+	var configs []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &configs); err != nil || len(configs) != 1 {
+		t.Errorf("Expected only JSON on stdout, got %q", stdout)
+	}
+	if !strings.Contains(stderr.String(), "Dry run") {
+		t.Errorf("Expected the dry-run plan on stderr, got %q", stderr)
+	}
+}
+
+func TestMetadataServerUsesSharedADC(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	gcloudtest.New().Install(t)
+
+	sharedADC, err := config.GetADCFileForCredential(config.CredentialKey("jane@example.com", ""))
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	cfg := &config.GCloudConfig{Name: "dev", ProjectID: "dev-project", Account: "jane@example.com"}
+	if _, err := newMetadataServer(context.Background(), cfg, false); err == nil {
+		t.Error("Expected an error without any saved ADC")
+	}
+
+	if err := os.WriteFile(sharedADC, []byte(`{"type": "authorized_user"}`), 0600); err != nil {
+		t.Fatalf("Failed to write shared ADC: %v", err)
+	}
+	server, err := newMetadataServer(context.Background(), cfg, false)
+	if err != nil {
+		t.Fatalf("Expected the ADC shared by the account to be served, got: %v", err)
+	}
+	if server.Email != "jane@example.com" {
+		t.Errorf("Unexpected email: %s", server.Email)
+	}
+}
+
+func TestLogoutKeepsImpersonatedADCUnrevoked(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	defer func() { logoutAll, logoutYes = false, false }()
+
+	savedADC := filepath.Join(t.TempDir(), "prod.json")
+	if err := os.WriteFile(savedADC, []byte(`{"type": "impersonated_service_account", "source_credentials": {"type": "authorized_user"}}`), 0600); err != nil {
+		t.Fatalf("Failed to write saved ADC: %v", err)
+	}
+	store := &config.ConfigStore{
+		Configurations: []config.GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project"},
+			{Name: "prod", ProjectID: "prod-project", ServiceAccount: "deployer@prod-project.iam.gserviceaccount.com", ADCPath: savedADC},
+			{Name: "ci", ProjectID: "ci-project"},
+		},
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	accountQuery := "config configurations describe %s --format=value(properties.core.account)"
+	fake := gcloudtest.New().
+		On("config configurations list --filter=is_active:true", "other\n", nil).
+		On(fmt.Sprintf(accountQuery, "dev"), "jane@example.com\n", nil).
+		On(fmt.Sprintf(accountQuery, "prod"), "bob@example.com\n", nil).
+		On(fmt.Sprintf(accountQuery, "ci"), "carol@example.com\n", nil).
+		Install(t)
+
+	if _, err := executeCommand(rootCmd, "logout", "--all", "--yes"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	queries := 0
+	for _, inv := range fake.Invocations() {
+		if strings.HasSuffix(inv, "--format=value(properties.core.account)") {
+			queries++
+		}
+		if strings.HasPrefix(inv, "auth application-default revoke") {
+			t.Errorf("Expected the impersonated ADC not to be revoked, got: %s", inv)
+		}
+	}
+	// One lookup per logged out configuration, plus one per configuration for the shared accounts
+	if queries != 6 {
+		t.Errorf("Expected the accounts of the configurations to be looked up once, got %d queries", queries)
+	}
+	if _, err := os.Stat(savedADC); !os.IsNotExist(err) {
+		t.Errorf("Expected the impersonated ADC to be deleted, got: %v", err)
+	}
+}
+
+func TestConfirmPromptsOnStderr(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := &config.ConfigStore{Configurations: []config.GCloudConfig{{Name: "prod", ProjectID: "prod-project", Aliases: []string{"p"}}}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	answer := filepath.Join(t.TempDir(), "answer")
+	if err := os.WriteFile(answer, []byte("n\n"), 0600); err != nil {
+		t.Fatalf("Failed to write answer: %v", err)
+	}
+	stdin, err := os.Open(answer) //nolint:gosec
+	if err != nil {
+		t.Fatalf("Failed to open answer: %v", err)
+	}
+	defer stdin.Close() //nolint:errcheck
+	previousStdin := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = previousStdin }()
+
+	stdout, stderr, err := executeCommandSplit(rootCmd, "remove", "p")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "Remove 'prod'? [y/N]") || strings.Contains(stdout.String(), "[y/N]") {
+		t.Errorf("Expected the question on stderr only, got stdout %q and stderr %q", stdout, stderr)
+	}
+	if loaded, _ := config.LoadConfigStore(); len(loaded.Configurations) != 1 {
+		t.Error("Expected the configuration to be kept after answering no")
+	}
+}
+
+func TestDryRunPlanIgnoresQuiet(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func() { dryRun, quiet, editTags = false, false, nil; dryrun.Reset() }()
+	store := &config.ConfigStore{Configurations: []config.GCloudConfig{{Name: "dev", ProjectID: "dev-project"}}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Failed to save store: %v", err)
+	}
+
+	stdout, stderr, err := executeCommandSplit(rootCmd, "--dry-run", "--quiet", "edit", "dev", "--tag", "env:dev")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	printPlan(stderr)
+	if stdout.Len() > 0 {
+		t.Errorf("Expected no messages with --quiet, got %q", stdout)
+	}
+	if !strings.Contains(stderr.String(), "Dry run: the following operations would be performed") || !strings.Contains(stderr.String(), "  1. ") {
+		t.Errorf("Expected the plan despite --quiet, got %q", stderr)
+	}
+}
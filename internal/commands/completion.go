@@ -3,20 +3,43 @@ package commands
 
 import (
 	"gcloud-switch/internal/config"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-// GetConfigNames returns a list of all configuration names for autocompletion
-func GetConfigNames(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+// GetConfigNames returns a list of all configuration names for autocompletion.
+// Aliases and tags are shown as descriptions, and a --tag flag already on the command line
+// narrows the candidates to the configurations carrying those tags.
+func GetConfigNames(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
 	store, err := config.LoadConfigStore()
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
+	var tags []string
+	if cmd != nil {
+		if flag := cmd.Flags().Lookup("tag"); flag != nil && flag.Changed {
+			values, _ := cmd.Flags().GetStringSlice("tag")
+			tags, _ = config.ParseTags(values)
+		}
+	}
+
 	var names []string
 	for _, cfg := range store.Configurations {
-		names = append(names, cfg.Name)
+		if !cfg.MatchesTags(tags) {
+			continue
+		}
+		var details []string
+		if len(cfg.Aliases) > 0 {
+			details = append(details, "aliases: "+strings.Join(cfg.Aliases, ", "))
+		}
+		details = append(details, cfg.Tags...)
+		if len(details) > 0 {
+			names = append(names, cfg.Name+"\t"+strings.Join(details, ", "))
+		} else {
+			names = append(names, cfg.Name)
+		}
 	}
 
 	return names, cobra.ShellCompDirectiveNoFileComp
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"os"
+	"sync"
+	"time"
+)
+
+// credentialMargin is how long before the cached token expiry credentials are checked for real again
+const credentialMargin = 5 * time.Minute
+
+// validateCredentials reports whether the account and ADC credentials of the active gcloud
+// configuration are valid. Unless revalidate is set, a previous successful check of key is
+// trusted until shortly before its tokens expire, avoiding the slow gcloud calls.
+// The outcome of a real check is recorded in state.
+func validateCredentials(ctx context.Context, state *config.State, key string, revalidate bool) (accountValid, adcValid, cached bool) {
+	now := time.Now()
+	if !revalidate && state.Credentials[key].Fresh(now, credentialMargin) {
+		return true, true, true
+	}
+
+	// Both checks are independent and each can take a few seconds
+	var (
+		wg                 sync.WaitGroup
+		accountToken       gcloud.AccessToken
+		adcToken           gcloud.AccessToken
+		accountErr, adcErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		accountToken, accountErr = gcloud.PrintAccessToken(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		adcToken, adcErr = gcloud.PrintADCAccessToken(ctx)
+	}()
+	wg.Wait()
+	accountValid, adcValid = accountErr == nil, adcErr == nil
+
+	var expiresAt time.Time
+	if accountValid && adcValid {
+		expiresAt = accountToken.Expiry
+		if adcToken.Expiry.Before(expiresAt) {
+			expiresAt = adcToken.Expiry
+		}
+	}
+	state.RecordCredentials(key, accountValid && adcValid, now, expiresAt)
+	return accountValid, adcValid, false
+}
+
+// lacksADCSettings reports whether the last recorded login of credentials obtained the ADC
+// without the scopes or OAuth client of cfg. Without a recorded login, e.g. after state.json
+// was deleted, the saved ADC is assumed to match as it is stored under a key including them.
+func lacksADCSettings(credentials config.CredentialState, cfg *config.GCloudConfig) bool {
+	return !credentials.LoggedInAt.IsZero() && !credentials.Covers(cfg.ADCScopes, cfg.ClientIDFile)
+}
+
+// configADCPath returns the ADC file holding the credentials of a configuration: the live
+// ADC for the active configuration, else its saved ADC or the one shared by its account
+func configADCPath(cfg *config.GCloudConfig, account string, active bool) string {
+	if active {
+		adcPath, _ := gcloud.GetADCPath()
+		return adcPath
+	}
+	if cfg.ADCPath != "" || account == "" {
+		return cfg.ADCPath
+	}
+	if sharedPath, err := config.GetADCFileForCredential(cfg.CredentialKey(account)); err == nil {
+		if _, err := os.Stat(sharedPath); err == nil {
+			return sharedPath
+		}
+	}
+	return ""
+}
@@ -1,27 +1,45 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"gcloud-switch/internal/config"
 	"gcloud-switch/internal/gcloud"
 	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/output"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var currentRevalidate bool
+
 var currentCmd = &cobra.Command{
 	Use:   "current",
 	Short: "Show the current active GCloud configuration",
 	Long:  `Display information about the currently active configuration.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
 		store, err := config.LoadConfigStore()
 		if err != nil {
 			return fmt.Errorf("failed to load configurations: %w", err)
 		}
 
+		if !format.IsText() {
+			view, err := buildCurrentView(ctx, store)
+			if err != nil {
+				return err
+			}
+			return output.Write(cmd.OutOrStdout(), format, view)
+		}
+
 		// Get the actual active gcloud configuration
-		activeGcloudConfig, err := gcloud.GetActiveConfiguration()
+		activeGcloudConfig, err := gcloud.GetActiveConfiguration(ctx)
 		if err == nil {
 			activeGcloudConfig = strings.TrimSpace(activeGcloudConfig)
 			logger.Info("Active GCloud Configuration", "name", activeGcloudConfig)
@@ -43,24 +61,81 @@ var currentCmd = &cobra.Command{
 		logger.Info("Project ID", "project_id", cfg.ProjectID)
 		if cfg.ServiceAccount != "" {
 			logger.Info("Service Account", "service_account", cfg.ServiceAccount)
+			if len(cfg.Delegates) > 0 {
+				logger.Info("Via Delegates", "delegates", strings.Join(cfg.Delegates, " → "))
+			}
 		} else {
 			logger.Info("Service Account: (none - using user credentials)")
 		}
+		if cfg.Account != "" {
+			logger.Info("Account", "account", cfg.Account)
+		}
+		logADCLoginSettings("", *cfg)
 
 		// Also show current gcloud project
-		currentProject, err := gcloud.GetCurrentProject()
+		currentProject, err := gcloud.GetCurrentProject(ctx)
 		if err == nil {
 			currentProject = strings.TrimSpace(currentProject)
 			logger.Info("Current GCloud Project", "project", currentProject)
 		}
 
 		// Show if ADC is valid
-		if gcloud.CheckADCValid() {
+		if currentADCValid(ctx, cfg.Name) {
 			logger.Success("ADC credentials are valid")
 		} else {
 			logger.Warning("ADC credentials are invalid or expired")
 		}
 
+		// Changes made directly in gcloud since the last switch
+		if items := detectDrift(ctx, store); len(items) > 0 {
+			for _, item := range items {
+				logger.Warning("gcloud differs from gcloud-switcher", "field", item.Field, "store", item.Store, "gcloud", item.GCloud)
+			}
+			logger.Info("Run 'gcloud-switcher sync' to apply the gcloud-switcher values, or 'gcloud-switcher sync --from gcloud' to keep the gcloud ones")
+		}
+
 		return nil
 	},
 }
+
+// buildCurrentView gathers the active state for machine-readable output
+func buildCurrentView(ctx context.Context, store *config.ConfigStore) (currentView, error) {
+	view := currentView{ActiveConfig: store.ActiveConfig}
+
+	if activeGcloudConfig, err := gcloud.GetActiveConfiguration(ctx); err == nil {
+		view.GCloudConfiguration = strings.TrimSpace(activeGcloudConfig)
+	}
+	if currentProject, err := gcloud.GetCurrentProject(ctx); err == nil {
+		view.GCloudProject = strings.TrimSpace(currentProject)
+	}
+	if store.ActiveConfig == "" {
+		view.ADCValid = gcloud.CheckADCValid(ctx)
+	} else {
+		cfg, err := store.FindConfig(store.ActiveConfig)
+		if err != nil {
+			return view, fmt.Errorf("active configuration not found: %w", err)
+		}
+		view.ADCValid = currentADCValid(ctx, cfg.Name)
+		cfgView := newConfigView(*cfg, store.ActiveConfig)
+		view.Configuration = &cfgView
+	}
+	view.Drift = []driftView{}
+	for _, item := range detectDrift(ctx, store) {
+		view.Drift = append(view.Drift, driftView{Field: item.Field, Store: item.Store, GCloud: item.GCloud})
+	}
+	return view, nil
+}
+
+// currentADCValid checks the ADC of the active configuration, trusting a fresh cached check
+func currentADCValid(ctx context.Context, name string) bool {
+	state := config.LoadState()
+	_, adcValid, _ := validateCredentials(ctx, state, state.CredentialKey(name), currentRevalidate)
+	if err := state.Save(); err != nil {
+		logger.Debug("Failed to save state", "error", err)
+	}
+	return adcValid
+}
+
+func init() {
+	currentCmd.Flags().BoolVar(&currentRevalidate, "revalidate", false, "Check the credentials with gcloud even if a cached check is still valid")
+}
@@ -0,0 +1,436 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// checkStatus is the outcome of a single doctor check
+type checkStatus int
+
+const (
+	statusPass checkStatus = iota
+	statusWarn
+	statusFail
+)
+
+// checkResult describes the outcome of a doctor check, with an optional safe repair
+type checkResult struct {
+	Name       string
+	Status     checkStatus
+	Message    string
+	Suggestion string
+	Fix        func() error
+}
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the gcloud-switcher and gcloud environment",
+	Long: `Run a series of checks on the gcloud installation, the gcloud-switcher
+configuration store, native gcloud configurations, stored ADC files and
+credentials. Use --fix to apply the safe repairs that are suggested.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		results := runDoctorChecks(ctx)
+
+		failures := 0
+		for _, result := range results {
+			printCheckResult(result)
+
+			if result.Status != statusPass && doctorFix && result.Fix != nil {
+				if err := result.Fix(); err != nil {
+					logger.Error("  Fix failed", "error", err)
+				} else {
+					logger.Success("  Fixed")
+					continue
+				}
+			}
+			if result.Status == statusFail {
+				failures++
+			}
+		}
+
+		if failures > 0 {
+			return fmt.Errorf("doctor found %d failing check(s)", failures)
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Apply safe repairs for the detected problems")
+}
+
+// runDoctorChecks runs every check and returns the results in order
+func runDoctorChecks(ctx context.Context) []checkResult {
+	var results []checkResult
+
+	installed := gcloud.IsInstalled()
+	results = append(results, checkGCloudInstalled(ctx, installed))
+	results = append(results, checkGCloudConfigDir())
+
+	store, storeResult := checkStore()
+	results = append(results, storeResult)
+
+	if store != nil {
+		results = append(results, checkPermissions(store)...)
+		results = append(results, checkADCFiles(store)...)
+	}
+
+	// The remaining checks need a working gcloud and a readable store
+	if !installed || store == nil {
+		return results
+	}
+
+	results = append(results, checkNativeConfigurations(ctx, store)...)
+	results = append(results, checkActiveConfig(ctx, store))
+	results = append(results, checkCredentials(ctx, store)...)
+
+	return results
+}
+
+func printCheckResult(result checkResult) {
+	switch result.Status {
+	case statusPass:
+		logger.Success(result.Name + ": " + result.Message)
+	case statusWarn:
+		logger.Warning(result.Name + ": " + result.Message)
+	case statusFail:
+		logger.Error(result.Name + ": " + result.Message)
+	}
+	if result.Status != statusPass && result.Suggestion != "" {
+		logger.Info("  Suggestion: " + result.Suggestion)
+	}
+}
+
+func checkGCloudInstalled(ctx context.Context, installed bool) checkResult {
+	result := checkResult{Name: "gcloud"}
+	if !installed {
+		result.Status = statusFail
+		result.Message = "gcloud binary not found in PATH"
+		result.Suggestion = "install the Google Cloud SDK: https://cloud.google.com/sdk/docs/install"
+		return result
+	}
+
+	version, err := gcloud.GetVersion(ctx)
+	if err != nil || version == "" {
+		result.Status = statusWarn
+		result.Message = "gcloud is installed but its version could not be determined"
+		result.Suggestion = "run 'gcloud version' to check the installation"
+		return result
+	}
+	result.Message = "Google Cloud SDK " + version
+	return result
+}
+
+func checkGCloudConfigDir() checkResult {
+	result := checkResult{Name: "gcloud config directory"}
+	dir, err := gcloud.GetConfigDir()
+	if err != nil {
+		result.Status = statusFail
+		result.Message = err.Error()
+		return result
+	}
+
+	source := "default location"
+	if os.Getenv("CLOUDSDK_CONFIG") != "" {
+		source = "from CLOUDSDK_CONFIG"
+	}
+	if _, err := os.Stat(dir); err != nil {
+		result.Status = statusWarn
+		result.Message = fmt.Sprintf("%s (%s) does not exist", dir, source)
+		result.Suggestion = "run 'gcloud init' or check the CLOUDSDK_CONFIG variable"
+		return result
+	}
+	result.Message = fmt.Sprintf("%s (%s)", dir, source)
+	return result
+}
+
+func checkStore() (*config.ConfigStore, checkResult) {
+	result := checkResult{Name: "configuration store"}
+	configPath, _ := config.GetConfigPath()
+
+	store, err := config.LoadConfigStore()
+	if err != nil {
+		result.Status = statusFail
+		result.Message = fmt.Sprintf("%s cannot be read: %v", configPath, err)
+		result.Suggestion = "fix or remove the file, a new one is created on the next 'add'"
+		return nil, result
+	}
+
+	seen := make(map[string]bool)
+	var problems []string
+	for _, cfg := range store.Configurations {
+		if seen[cfg.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate configuration '%s'", cfg.Name))
+		}
+		seen[cfg.Name] = true
+		if cfg.ProjectID == "" {
+			problems = append(problems, fmt.Sprintf("configuration '%s' has no project ID", cfg.Name))
+		}
+	}
+	if len(problems) > 0 {
+		result.Status = statusWarn
+		result.Message = strings.Join(problems, ", ")
+		result.Suggestion = "use 'gcloud-switcher edit' or 'gcloud-switcher remove' to clean up"
+		return store, result
+	}
+
+	result.Message = fmt.Sprintf("%d configuration(s) in %s", len(store.Configurations), configPath)
+	return store, result
+}
+
+// checkPermissions verifies that stored credentials are not readable by other users
+func checkPermissions(store *config.ConfigStore) []checkResult {
+	// Unix permission bits are not meaningful on Windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	var paths []string
+	if configPath, err := config.GetConfigPath(); err == nil {
+		paths = append(paths, configPath)
+	}
+	for _, cfg := range store.Configurations {
+		if cfg.ADCPath != "" && !slices.Contains(paths, cfg.ADCPath) {
+			paths = append(paths, cfg.ADCPath)
+		}
+	}
+
+	var insecure []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			insecure = append(insecure, path)
+		}
+	}
+
+	result := checkResult{Name: "file permissions"}
+	if len(insecure) == 0 {
+		result.Message = "configuration and credential files are private"
+		return []checkResult{result}
+	}
+	result.Status = statusWarn
+	result.Message = "readable by other users: " + strings.Join(insecure, ", ")
+	result.Suggestion = "restrict the files to mode 0600"
+	result.Fix = func() error {
+		for _, path := range insecure {
+			if dryrun.Enabled() {
+				dryrun.Record("chmod 0600 %s", path)
+				continue
+			}
+			if err := os.Chmod(path, 0600); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return []checkResult{result}
+}
+
+// checkADCFiles looks for missing ADC files referenced by the store and orphaned ones on disk
+func checkADCFiles(store *config.ConfigStore) []checkResult {
+	var results []checkResult
+
+	for i := range store.Configurations {
+		cfg := &store.Configurations[i]
+		if cfg.ADCPath == "" {
+			continue
+		}
+		if _, err := os.Stat(cfg.ADCPath); err == nil {
+			continue
+		}
+		results = append(results, checkResult{
+			Name:       "stored ADC",
+			Status:     statusWarn,
+			Message:    fmt.Sprintf("configuration '%s' references missing file %s", cfg.Name, cfg.ADCPath),
+			Suggestion: "clear the reference, credentials are saved again on the next switch",
+			Fix: func() error {
+				cfg.ADCPath = ""
+				return store.Save()
+			},
+		})
+	}
+
+	adcDir, err := config.GetADCStoragePath()
+	if err != nil {
+		return results
+	}
+	entries, err := os.ReadDir(adcDir)
+	if err != nil {
+		return results
+	}
+
+	var orphans []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if _, err := store.FindConfig(name); err != nil {
+			orphans = append(orphans, filepath.Join(adcDir, entry.Name()))
+		}
+	}
+	if sharedDir, err := config.GetSharedADCStoragePath(); err == nil {
+		entries, _ := os.ReadDir(sharedDir)
+		for _, entry := range entries {
+			path := filepath.Join(sharedDir, entry.Name())
+			if !entry.IsDir() && filepath.Ext(path) == ".json" && len(store.ConfigsUsingADC(path, "")) == 0 {
+				orphans = append(orphans, path)
+			}
+		}
+	}
+	if len(orphans) > 0 {
+		results = append(results, checkResult{
+			Name:       "stored ADC",
+			Status:     statusWarn,
+			Message:    "files without a matching configuration: " + strings.Join(orphans, ", "),
+			Suggestion: "run 'gcloud-switcher prune' to delete them",
+		})
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: "stored ADC", Message: "all stored ADC files are consistent"})
+	}
+	return results
+}
+
+// checkNativeConfigurations verifies that every configuration has a native gcloud counterpart
+func checkNativeConfigurations(ctx context.Context, store *config.ConfigStore) []checkResult {
+	native, err := gcloud.ListConfigurations(ctx)
+	if err != nil {
+		return []checkResult{{
+			Name:    "native configurations",
+			Status:  statusWarn,
+			Message: err.Error(),
+		}}
+	}
+
+	existing := make(map[string]bool, len(native))
+	for _, name := range native {
+		existing[name] = true
+	}
+
+	var results []checkResult
+	for _, cfg := range store.Configurations {
+		if existing[cfg.Name] {
+			continue
+		}
+		name, project := cfg.Name, cfg.ProjectID
+		results = append(results, checkResult{
+			Name:       "native configurations",
+			Status:     statusWarn,
+			Message:    fmt.Sprintf("configuration '%s' has no native gcloud configuration", name),
+			Suggestion: "create it with 'gcloud config configurations create " + name + "'",
+			Fix: func() error {
+				if err := gcloud.CreateConfiguration(ctx, name); err != nil {
+					return err
+				}
+				store.MarkOwned(name)
+				if err := store.Save(); err != nil {
+					return err
+				}
+				return gcloud.SetProjectForConfiguration(ctx, name, project)
+			},
+		})
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{Name: "native configurations", Message: "every configuration has a native gcloud configuration"})
+	}
+	return results
+}
+
+// checkActiveConfig compares the tracked active configuration with the one gcloud uses
+func checkActiveConfig(ctx context.Context, store *config.ConfigStore) checkResult {
+	result := checkResult{Name: "active configuration"}
+
+	activeGcloudConfig, err := gcloud.GetActiveConfiguration(ctx)
+	if err != nil {
+		result.Status = statusWarn
+		result.Message = err.Error()
+		return result
+	}
+	activeGcloudConfig = strings.TrimSpace(activeGcloudConfig)
+
+	if store.ActiveConfig == "" {
+		result.Message = fmt.Sprintf("gcloud uses '%s', none tracked by gcloud-switcher", activeGcloudConfig)
+		return result
+	}
+
+	if _, err := store.FindConfig(store.ActiveConfig); err != nil {
+		result.Status = statusWarn
+		result.Message = fmt.Sprintf("tracked active configuration '%s' no longer exists", store.ActiveConfig)
+		result.Suggestion = "clear the tracked active configuration"
+		result.Fix = func() error {
+			store.ActiveConfig = ""
+			return store.Save()
+		}
+		return result
+	}
+
+	if store.ActiveConfig != activeGcloudConfig {
+		result.Status = statusWarn
+		result.Message = fmt.Sprintf("gcloud-switcher tracks '%s' but gcloud uses '%s'", store.ActiveConfig, activeGcloudConfig)
+		result.Suggestion = "run 'gcloud-switcher switch <name>' to realign them"
+		return result
+	}
+
+	result.Message = fmt.Sprintf("'%s' is active in both gcloud and gcloud-switcher", activeGcloudConfig)
+	return result
+}
+
+// checkCredentials verifies the account and ADC credentials of the active configuration
+func checkCredentials(ctx context.Context, store *config.ConfigStore) []checkResult {
+	suggestion := "run 'gcloud-switcher switch <name>' to log in again"
+	if store.ActiveConfig != "" {
+		suggestion = "run 'gcloud-switcher switch " + store.ActiveConfig + "' to log in again"
+	}
+
+	var accountValid, adcValid bool
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		accountValid = gcloud.CheckAccountValid(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		adcValid = gcloud.CheckADCValid(ctx)
+	}()
+	wg.Wait()
+
+	account := checkResult{Name: "account credentials", Message: "valid"}
+	if !accountValid {
+		account.Status = statusWarn
+		account.Message = "invalid or expired"
+		account.Suggestion = suggestion
+	}
+
+	adc := checkResult{Name: "ADC credentials", Message: "valid"}
+	if !adcValid {
+		adc.Status = statusWarn
+		adc.Message = "invalid or expired"
+		adc.Suggestion = suggestion
+	}
+
+	return []checkResult{account, adc}
+}
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"slices"
+	"strings"
+)
+
+// Fields compared between the gcloud-switcher store and gcloud
+const (
+	driftActive        = "active configuration"
+	driftProject       = "project"
+	driftAccount       = "account"
+	driftImpersonation = "impersonated service account"
+	driftADC           = "ADC identity"
+)
+
+// driftItem is a value on which the gcloud-switcher store and gcloud disagree.
+// ToGCloud makes gcloud match the store and ToStore the opposite; either is nil
+// when that side cannot be changed automatically.
+type driftItem struct {
+	Field    string
+	Store    string
+	GCloud   string
+	ToGCloud func(ctx context.Context) error
+	ToStore  func() error
+}
+
+// detectDrift compares the active configuration of the store with gcloud: the active
+// native configuration, the properties of its native configuration and the live ADC
+func detectDrift(ctx context.Context, store *config.ConfigStore) []driftItem {
+	if store.ActiveConfig == "" {
+		return nil
+	}
+	cfg, err := store.FindConfig(store.ActiveConfig)
+	if err != nil {
+		return nil
+	}
+
+	var items []driftItem
+	nativeActive, _ := gcloud.GetActiveConfiguration(ctx)
+	nativeActive = strings.TrimSpace(nativeActive)
+	if nativeActive != cfg.Name {
+		item := driftItem{
+			Field:  driftActive,
+			Store:  cfg.Name,
+			GCloud: orNone(nativeActive),
+			ToGCloud: func(ctx context.Context) error {
+				if !gcloud.ConfigurationExists(ctx, cfg.Name) {
+					if err := gcloud.CreateConfiguration(ctx, cfg.Name); err != nil {
+						return err
+					}
+					store.MarkOwned(cfg.Name)
+				}
+				return gcloud.ActivateConfiguration(ctx, cfg.Name)
+			},
+		}
+		if _, err := store.FindConfig(nativeActive); err == nil {
+			item.ToStore = func() error {
+				store.ActiveConfig = nativeActive
+				return nil
+			}
+		}
+		items = append(items, item)
+	}
+
+	// A missing native configuration is created by reconciling the active one
+	if properties, err := gcloud.GetConfigurationProperties(ctx, cfg.Name); err == nil {
+		items = append(items, propertyDrift(cfg, properties)...)
+	}
+	if item, ok := adcDrift(cfg); ok {
+		items = append(items, item)
+	}
+	return items
+}
+
+// propertyDrift compares a configuration with the properties of its native configuration
+func propertyDrift(cfg *config.GCloudConfig, properties map[string]string) []driftItem {
+	var items []driftItem
+
+	if project := properties["core/project"]; project != cfg.ProjectID {
+		items = append(items, driftItem{
+			Field:  driftProject,
+			Store:  cfg.ProjectID,
+			GCloud: orNone(project),
+			ToGCloud: func(ctx context.Context) error {
+				return gcloud.SetProjectForConfiguration(ctx, cfg.Name, cfg.ProjectID)
+			},
+			ToStore: func() error {
+				if err := config.ValidateProjectID(project); err != nil {
+					return err
+				}
+				cfg.ProjectID = project
+				return nil
+			},
+		})
+	}
+
+	// Without a bound account any account logged in is fine
+	if account := properties["core/account"]; cfg.Account != "" && account != cfg.Account {
+		items = append(items, driftItem{
+			Field:  driftAccount,
+			Store:  cfg.Account,
+			GCloud: orNone(account),
+			ToGCloud: func(ctx context.Context) error {
+				return gcloud.SetConfigurationProperty(ctx, cfg.Name, "core/account", cfg.Account)
+			},
+			ToStore: func() error {
+				cfg.Account = account
+				return nil
+			},
+		})
+	}
+
+	// gcloud-switcher impersonates through ADC only, so an unset property is not drift
+	if impersonated := properties["auth/impersonate_service_account"]; impersonated != "" && impersonated != cfg.ImpersonationChain() {
+		items = append(items, driftItem{
+			Field:  driftImpersonation,
+			Store:  orNone(cfg.ImpersonationChain()),
+			GCloud: impersonated,
+			ToGCloud: func(ctx context.Context) error {
+				if cfg.ServiceAccount == "" {
+					return gcloud.UnsetConfigurationProperty(ctx, cfg.Name, "auth/impersonate_service_account")
+				}
+				return gcloud.SetConfigurationProperty(ctx, cfg.Name, "auth/impersonate_service_account", cfg.ImpersonationChain())
+			},
+			ToStore: func() error {
+				serviceAccount, delegates := config.SplitImpersonationChain(impersonated)
+				if err := errors.Join(config.ValidateServiceAccount(serviceAccount), config.ValidateDelegates(delegates, serviceAccount)); err != nil {
+					return err
+				}
+				cfg.ServiceAccount, cfg.Delegates = serviceAccount, delegates
+				return nil
+			},
+		})
+	}
+	return items
+}
+
+// adcDrift compares a configuration with the identity of the live ADC
+func adcDrift(cfg *config.GCloudConfig) (driftItem, bool) {
+	identity, err := gcloud.ReadADCIdentity()
+	if err != nil {
+		return driftItem{}, false
+	}
+	if len(identityMismatches(cfg, identity)) == 0 {
+		return driftItem{}, false
+	}
+
+	item := driftItem{
+		Field:  driftADC,
+		Store:  describeIdentity(cfg.Account, formatChain(cfg.ServiceAccount, cfg.Delegates)),
+		GCloud: describeIdentity(identity.Account, formatChain(identity.ServiceAccount, identity.Delegates)),
+		ToGCloud: func(ctx context.Context) error {
+			if cfg.ADCPath == "" {
+				return errors.New("no saved ADC, run 'gcloud-switcher switch " + cfg.Name + "' to log in")
+			}
+			return gcloud.RestoreADC(cfg.ADCPath)
+		},
+	}
+	// Only impersonated ADC names its service account: user credentials may just come from a
+	// login without impersonation, and a service account key cannot be represented in the store
+	switch {
+	case identity.Type == gcloud.ADCImpersonated:
+		item.ToStore = func() error {
+			cfg.ServiceAccount, cfg.Delegates = identity.ServiceAccount, identity.Delegates
+			if cfg.Account != "" && identity.Account != "" {
+				cfg.Account = identity.Account
+			}
+			return nil
+		}
+	case identity.Type == gcloud.ADCAuthorizedUser && cfg.ServiceAccount == "":
+		item.ToStore = func() error {
+			if cfg.Account != "" && identity.Account != "" {
+				cfg.Account = identity.Account
+			}
+			return nil
+		}
+	}
+	return item, true
+}
+
+// identityMismatches lists how the identity of an ADC file differs from the account and
+// impersonation chain of a configuration
+func identityMismatches(cfg *config.GCloudConfig, identity gcloud.ADCIdentity) []string {
+	var mismatches []string
+	// gcloud does not always record the account in the ADC file
+	if cfg.Account != "" && identity.Account != "" && identity.Account != cfg.Account {
+		mismatches = append(mismatches, fmt.Sprintf("account is %s instead of %s", identity.Account, cfg.Account))
+	}
+	if identity.ServiceAccount != cfg.ServiceAccount {
+		mismatches = append(mismatches, fmt.Sprintf("service account is %s instead of %s", orNone(identity.ServiceAccount), orNone(cfg.ServiceAccount)))
+	} else if !slices.Equal(identity.Delegates, cfg.Delegates) {
+		mismatches = append(mismatches, fmt.Sprintf("delegates are %s instead of %s",
+			orNone(strings.Join(identity.Delegates, " → ")), orNone(strings.Join(cfg.Delegates, " → "))))
+	}
+	return mismatches
+}
+
+// describeIdentity formats an account and the service account it impersonates
+func describeIdentity(account, serviceAccount string) string {
+	switch {
+	case account != "" && serviceAccount != "":
+		return fmt.Sprintf("%s as %s", account, serviceAccount)
+	case serviceAccount != "":
+		return serviceAccount
+	default:
+		return orNone(account)
+	}
+}
+
+// orNone shows an empty value as (none)
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
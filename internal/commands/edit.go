@@ -15,14 +15,26 @@ import (
 var (
 	editProjectID      string
 	editServiceAccount string
+	editProtected      bool
+	editVerify         bool
+	editTags           []string
+	editUntags         []string
+	editAliases        []string
+	editUnaliases      []string
+	editBrowserMode    string
+	editAccount        string
+	editDelegates      []string
+	editADCScopes      []string
+	editClientID       string
 )
 var editCmd = &cobra.Command{
 	Use:               "edit <name>",
 	Short:             "Edit an existing GCloud configuration",
-	Long:              `Update the project ID or service account for an existing configuration.`,
+	Long:              `Update the project ID, service account or delegates of an existing configuration.`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: GetConfigNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
 		configName := args[0]
 
 		store, err := config.LoadConfigStore()
@@ -30,32 +42,80 @@ var editCmd = &cobra.Command{
 			return fmt.Errorf("failed to load configurations: %w", err)
 		}
 
-		cfg, err := store.FindConfig(configName)
+		cfg, err := resolveConfig(store, configName)
 		if err != nil {
-			return fmt.Errorf("configuration '%s' not found", configName)
+			return err
 		}
+		configName = cfg.Name
 
 		logger.Info("Editing configuration", "name", configName)
 		logger.Info("Current Project ID", "project_id", cfg.ProjectID)
-		logger.Info("Current Service Account", "service_account", cfg.ServiceAccount)
-		fmt.Println()
-
+		logger.Info("Current Service Account", "service_account", formatChain(cfg.ServiceAccount, cfg.Delegates))
 		reader := bufio.NewReader(os.Stdin)
 
-		// If flags not provided, prompt for them
-		if editProjectID == "" && !cmd.Flags().Changed("project") {
-			fmt.Printf("Enter new Project ID (or press Enter to keep current): ")
+		// If flags not provided, prompt for them. Changing only tags or protection needs no prompt.
+		metadataOnly := cmd.Flags().Changed("tag") || cmd.Flags().Changed("untag") || cmd.Flags().Changed("protected") ||
+			cmd.Flags().Changed("alias") || cmd.Flags().Changed("unalias") || cmd.Flags().Changed("browser-mode") ||
+			cmd.Flags().Changed("account") || cmd.Flags().Changed("delegate") || cmd.Flags().Changed("adc-scope") ||
+			cmd.Flags().Changed("client-id-file")
+		if editProjectID == "" && !cmd.Flags().Changed("project") && !metadataOnly {
+			_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Enter new Project ID (or press Enter to keep current): ") //nolint:errcheck
 			editProjectID, _ = reader.ReadString('\n')
 			editProjectID = strings.TrimSpace(editProjectID)
 		}
 
-		if editServiceAccount == "" && !cmd.Flags().Changed("service-account") {
-			fmt.Printf("Enter new Service Account (or press Enter to keep current): ")
+		if editServiceAccount == "" && !cmd.Flags().Changed("service-account") && !metadataOnly {
+			_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Enter new Service Account (or press Enter to keep current): ") //nolint:errcheck
 			editServiceAccount, _ = reader.ReadString('\n')
 			editServiceAccount = strings.TrimSpace(editServiceAccount)
 		}
 
+		if editProjectID != "" {
+			if err := config.ValidateProjectID(editProjectID); err != nil {
+				return err
+			}
+		}
+		if err := config.ValidateServiceAccount(editServiceAccount); err != nil {
+			return err
+		}
+
+		tags, err := config.ParseTags(editTags)
+		if err != nil {
+			return err
+		}
+		untags, err := config.ParseTags(editUntags)
+		if err != nil {
+			return err
+		}
+		aliases, err := config.ParseAliases(editAliases)
+		if err != nil {
+			return err
+		}
+		unaliases, err := config.ParseAliases(editUnaliases)
+		if err != nil {
+			return err
+		}
+		if err := checkAliases(store, configName, aliases); err != nil {
+			return err
+		}
+		browserMode, err := parseBrowserModeFlag(editBrowserMode)
+		if err != nil {
+			return err
+		}
+		if err := config.ValidateAccount(editAccount); err != nil {
+			return err
+		}
+		adcScopes := parseListFlag(editADCScopes)
+		if err := config.ValidateScopes(adcScopes); err != nil {
+			return err
+		}
+		clientIDFile, err := parseClientIDFile(editClientID)
+		if err != nil {
+			return err
+		}
+
 		projectChanged := false
+		previousCredentials := cfg.CredentialKey("")
 
 		// Update only if new values provided
 		if editProjectID != "" {
@@ -65,6 +125,50 @@ var editCmd = &cobra.Command{
 
 		if cmd.Flags().Changed("service-account") || editServiceAccount != "" {
 			cfg.ServiceAccount = editServiceAccount
+			// Delegates only lead to a service account
+			if cfg.ServiceAccount == "" {
+				cfg.Delegates = nil
+			}
+		}
+		if cmd.Flags().Changed("delegate") {
+			cfg.Delegates = parseListFlag(editDelegates)
+		}
+		if err := config.ValidateDelegates(cfg.Delegates, cfg.ServiceAccount); err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("protected") {
+			cfg.Protected = editProtected
+		}
+
+		accountChanged := cmd.Flags().Changed("account") && editAccount != cfg.Account
+		if accountChanged {
+			cfg.Account = editAccount
+		}
+
+		cfg.RemoveTags(untags)
+		cfg.AddTags(tags)
+		cfg.RemoveAliases(unaliases)
+		cfg.AddAliases(aliases)
+		if cmd.Flags().Changed("browser-mode") {
+			cfg.BrowserMode = browserMode
+		}
+		if cmd.Flags().Changed("adc-scope") {
+			cfg.ADCScopes = adcScopes
+		}
+		if cmd.Flags().Changed("client-id-file") {
+			cfg.ClientIDFile = clientIDFile
+		}
+		// The saved ADC was obtained for another impersonation chain, scopes or OAuth client;
+		// the next switch finds the ADC saved for the new ones or logs in again
+		if cfg.CredentialKey("") != previousCredentials {
+			cfg.ADCPath = ""
+		}
+
+		if editVerify {
+			if err := verifyConfigValues(ctx, cfg.ProjectID, cfg.ServiceAccount, cfg.Delegates); err != nil {
+				return fmt.Errorf("verification failed, configuration not updated:\n%w", err)
+			}
 		}
 
 		if err := store.Save(); err != nil {
@@ -72,17 +176,17 @@ var editCmd = &cobra.Command{
 		}
 
 		// If project ID changed, update it in the native gcloud configuration
-		if projectChanged && gcloud.ConfigurationExists(configName) {
+		if projectChanged && gcloud.ConfigurationExists(ctx, configName) {
 			logger.Info("Updating native gcloud configuration with new project ID")
 
 			// Get current active configuration to restore it later
-			currentActive, _ := gcloud.GetActiveConfiguration()
+			currentActive, _ := gcloud.GetActiveConfiguration(ctx)
 			currentActive = strings.TrimSpace(currentActive)
 
 			// Only activate the configuration if it's not already active
 			needsRestore := false
 			if currentActive != configName {
-				if err := gcloud.ActivateConfiguration(configName); err != nil {
+				if err := gcloud.ActivateConfiguration(ctx, configName); err != nil {
 					logger.Warning("Failed to activate configuration for update", "error", err)
 					// Continue anyway, might still work
 				} else {
@@ -91,7 +195,7 @@ var editCmd = &cobra.Command{
 			}
 
 			// Set the project ID
-			if err := gcloud.SetProject(cfg.ProjectID); err != nil {
+			if err := gcloud.SetProject(ctx, cfg.ProjectID); err != nil {
 				logger.Warning("Failed to update project in native gcloud configuration", "error", err)
 			} else {
 				logger.Success("Native gcloud configuration updated")
@@ -99,18 +203,43 @@ var editCmd = &cobra.Command{
 
 			// Restore the previously active configuration if we changed it
 			if needsRestore && currentActive != "" {
-				if err := gcloud.ActivateConfiguration(currentActive); err != nil {
+				if err := gcloud.ActivateConfiguration(ctx, currentActive); err != nil {
 					logger.Warning("Failed to restore previous active configuration", "error", err)
 				}
 			}
 		}
 
+		// The account can be set without activating the configuration
+		if accountChanged && gcloud.ConfigurationExists(ctx, configName) {
+			if cfg.Account == "" {
+				err = gcloud.UnsetConfigurationProperty(ctx, configName, "core/account")
+			} else {
+				err = gcloud.SetConfigurationProperty(ctx, configName, "core/account", cfg.Account)
+			}
+			if err != nil {
+				logger.Warning("Failed to update account in native gcloud configuration", "error", err)
+			}
+		}
+
 		logger.Success("Successfully updated configuration", "name", configName, "project_id", cfg.ProjectID)
 		if cfg.ServiceAccount != "" {
 			logger.Info("  Service Account", "service_account", cfg.ServiceAccount)
+			if len(cfg.Delegates) > 0 {
+				logger.Info("  Via Delegates", "delegates", strings.Join(cfg.Delegates, " → "))
+			}
 		} else {
 			logger.Info("  Service Account: (none)")
 		}
+		if cfg.Account != "" {
+			logger.Info("  Account", "account", cfg.Account)
+		}
+		if len(cfg.Tags) > 0 {
+			logger.Info("  Tags", "tags", strings.Join(cfg.Tags, ", "))
+		}
+		if len(cfg.Aliases) > 0 {
+			logger.Info("  Aliases", "aliases", strings.Join(cfg.Aliases, ", "))
+		}
+		logADCLoginSettings("  ", *cfg)
 
 		return nil
 	},
@@ -119,4 +248,18 @@ var editCmd = &cobra.Command{
 func init() {
 	editCmd.Flags().StringVarP(&editProjectID, "project", "p", "", "New GCloud Project ID")
 	editCmd.Flags().StringVarP(&editServiceAccount, "service-account", "s", "", "New Service Account to impersonate")
+	editCmd.Flags().StringSliceVar(&editDelegates, "delegate", nil, "Service accounts impersonated before the service account, in order (use --delegate \"\" for none)")
+	editCmd.Flags().StringVarP(&editAccount, "account", "a", "", "User account to log in with (use --account \"\" to allow any)")
+	editCmd.Flags().BoolVar(&editProtected, "protected", false, "Mark the configuration as sensitive (use --protected=false to unmark)")
+	editCmd.Flags().StringSliceVar(&editTags, "tag", nil, "Add tags to the configuration, e.g. --tag env:prod")
+	editCmd.Flags().StringSliceVar(&editUntags, "untag", nil, "Remove tags from the configuration")
+	_ = editCmd.RegisterFlagCompletionFunc("tag", GetTagNames)   //nolint:errcheck
+	_ = editCmd.RegisterFlagCompletionFunc("untag", GetTagNames) //nolint:errcheck
+	editCmd.Flags().StringSliceVar(&editAliases, "alias", nil, "Add short names accepted wherever the configuration name is")
+	editCmd.Flags().StringSliceVar(&editUnaliases, "unalias", nil, "Remove aliases from the configuration")
+	editCmd.Flags().StringVar(&editBrowserMode, "browser-mode", "auto", "How logins obtain consent: auto, browser, no-browser or no-launch-browser")
+	_ = editCmd.RegisterFlagCompletionFunc("browser-mode", cobra.FixedCompletions(browserModeValues, cobra.ShellCompDirectiveNoFileComp)) //nolint:errcheck
+	editCmd.Flags().StringSliceVar(&editADCScopes, "adc-scope", nil, "OAuth scopes requested for ADC in addition to gcloud's defaults (use --adc-scope \"\" for none)")
+	editCmd.Flags().StringVar(&editClientID, "client-id-file", "", "OAuth client secrets file used for ADC logins (use --client-id-file \"\" for gcloud's client)")
+	editCmd.Flags().BoolVar(&editVerify, "verify", false, "Check with gcloud that the project is accessible and the service account can be impersonated")
 }
@@ -4,41 +4,136 @@ import (
 	"fmt"
 	"gcloud-switch/internal/config"
 	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/output"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	listTags    []string
+	listGroupBy string
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available GCloud configurations",
-	Long:  `Display a list of all configured GCloud configurations with their details.`,
+	Long: `Display a list of all configured GCloud configurations with their details.
+
+Use --tag to show only the configurations carrying every given tag, and
+--group-by to group them by the value of a key:value tag (e.g. --group-by client).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
 		store, err := config.LoadConfigStore()
 		if err != nil {
 			return fmt.Errorf("failed to load configurations: %w", err)
 		}
 
+		tags, err := config.ParseTags(listTags)
+		if err != nil {
+			return err
+		}
+		configs := store.SelectByTags(tags)
+
+		if !format.IsText() {
+			view := make(configListView, 0, len(configs))
+			for _, cfg := range configs {
+				view = append(view, newConfigView(*cfg, store.ActiveConfig))
+			}
+			return output.Write(cmd.OutOrStdout(), format, view)
+		}
+
 		if len(store.Configurations) == 0 {
 			logger.Info("No configurations found. Use 'gcloud-switcher add' to create one.")
 			return nil
 		}
+		if len(configs) == 0 {
+			logger.Info("No configuration is tagged " + strings.Join(tags, ", "))
+			return nil
+		}
 
 		logger.Info("Available GCloud Configurations:")
 		logger.Info("================================")
-		for _, cfg := range store.Configurations {
-			activeMarker := ""
-			if cfg.Name == store.ActiveConfig {
-				activeMarker = " (active)"
-			}
-			logger.Info("", "name", cfg.Name+activeMarker)
-			logger.Info("  Project ID", "project_id", cfg.ProjectID)
-			if cfg.ServiceAccount != "" {
-				logger.Info("  Service Account", "service_account", cfg.ServiceAccount)
+		if listGroupBy == "" {
+			printConfigs(configs, store.ActiveConfig, "")
+			return nil
+		}
+
+		groups, keys := groupByTag(configs, listGroupBy)
+		for _, key := range keys {
+			logger.Info("")
+			if key == "" {
+				logger.Info("(no " + listGroupBy + ")")
 			} else {
-				logger.Info("  Service Account: (none - using user credentials)")
+				logger.Info(listGroupBy + ":" + key)
 			}
+			printConfigs(groups[key], store.ActiveConfig, "  ")
 		}
 
 		return nil
 	},
 }
+
+func init() {
+	addTagSelectorFlag(listCmd, &listTags, "Only list configurations with these tags (e.g. env:prod)")
+	listCmd.Flags().StringVar(&listGroupBy, "group-by", "", "Group the configurations by the value of a tag key (e.g. client)")
+}
+
+// printConfigs prints the human-readable details of configurations
+func printConfigs(configs []*config.GCloudConfig, activeConfig, indent string) {
+	for _, cfg := range configs {
+		activeMarker := ""
+		if cfg.Name == activeConfig {
+			activeMarker = " (active)"
+		}
+		if cfg.Protected {
+			activeMarker += " [protected]"
+		}
+		logger.Info(indent, "name", cfg.Name+activeMarker)
+		logger.Info(indent+"  Project ID", "project_id", cfg.ProjectID)
+		if cfg.ServiceAccount != "" {
+			logger.Info(indent+"  Service Account", "service_account", cfg.ServiceAccount)
+			if len(cfg.Delegates) > 0 {
+				logger.Info(indent+"  Via Delegates", "delegates", strings.Join(cfg.Delegates, " → "))
+			}
+		} else {
+			logger.Info(indent + "  Service Account: (none - using user credentials)")
+		}
+		if cfg.Account != "" {
+			logger.Info(indent+"  Account", "account", cfg.Account)
+		}
+		if len(cfg.Aliases) > 0 {
+			logger.Info(indent+"  Aliases", "aliases", strings.Join(cfg.Aliases, ", "))
+		}
+		if len(cfg.Tags) > 0 {
+			logger.Info(indent+"  Tags", "tags", strings.Join(cfg.Tags, ", "))
+		}
+		logADCLoginSettings(indent+"  ", *cfg)
+	}
+}
+
+// groupByTag groups configurations by the value of their key:value tag with the given key.
+// The returned keys are sorted, with configurations lacking the tag last under "".
+func groupByTag(configs []*config.GCloudConfig, key string) (map[string][]*config.GCloudConfig, []string) {
+	groups := make(map[string][]*config.GCloudConfig)
+	var keys []string
+	for _, cfg := range configs {
+		value, _ := cfg.TagValue(key)
+		if _, ok := groups[value]; !ok {
+			keys = append(keys, value)
+		}
+		groups[value] = append(groups[value], cfg)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "" || keys[j] == "" {
+			return keys[j] == ""
+		}
+		return keys[i] < keys[j]
+	})
+	return groups, keys
+}
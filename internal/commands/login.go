@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loginOptions decides how a login for cfg is performed. The browser mode comes from
+// the global flags, then the configuration, then the detection of headless sessions.
+func loginOptions(cfg *config.GCloudConfig) gcloud.LoginOptions {
+	var mode gcloud.BrowserMode
+	switch {
+	case noBrowser:
+		mode = gcloud.BrowserNone
+	case noLaunchBrowser:
+		mode = gcloud.BrowserManual
+	case cfg.BrowserMode != "":
+		mode = gcloud.BrowserMode(cfg.BrowserMode)
+	case gcloud.IsHeadless():
+		logger.Info("No local browser available (SSH session or no display), using a browser-less login")
+		logger.Info("  Use 'gcloud-switcher edit " + cfg.Name + " --browser-mode browser' to always launch a browser")
+		mode = gcloud.BrowserManual
+	default:
+		mode = gcloud.BrowserLaunch
+	}
+
+	switch mode {
+	case gcloud.BrowserNone:
+		logger.Info("gcloud will print a command to run on a second machine that has a browser and gcloud installed.")
+		logger.Info("  Run it there, then paste its output back here.")
+	case gcloud.BrowserManual:
+		logger.Info("gcloud will print a URL: open it in a browser on any machine, sign in,")
+		logger.Info("  then paste the verification code back here.")
+	}
+	return gcloud.LoginOptions{Browser: mode, Account: cfg.Account, Scopes: cfg.ADCScopes, ClientIDFile: cfg.ClientIDFile}
+}
+
+// parseClientIDFile checks that a --client-id-file exists and makes its path absolute,
+// as logins run from any directory; empty means gcloud's own client
+func parseClientIDFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return "", fmt.Errorf("invalid client ID file: %w", err)
+	}
+	return absPath, nil
+}
+
+// logADCLoginSettings shows the extra ADC scopes and OAuth client of a configuration, if any
+func logADCLoginSettings(indent string, cfg config.GCloudConfig) {
+	if len(cfg.ADCScopes) > 0 {
+		logger.Info(indent+"ADC Scopes", "scopes", strings.Join(cfg.ADCScopes, ", "))
+	}
+	if cfg.ClientIDFile != "" {
+		logger.Info(indent+"OAuth Client", "client_id_file", cfg.ClientIDFile)
+	}
+}
+
+// parseBrowserModeFlag parses a --browser-mode value; auto clears the per-configuration mode
+func parseBrowserModeFlag(value string) (string, error) {
+	if value == "auto" || value == "" {
+		return "", nil
+	}
+	mode, err := gcloud.ParseBrowserMode(value)
+	return string(mode), err
+}
+
+// browserModeValues are the accepted --browser-mode values, for completion
+var browserModeValues = []string{"auto", string(gcloud.BrowserLaunch), string(gcloud.BrowserNone), string(gcloud.BrowserManual)}
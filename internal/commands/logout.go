@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logoutAll  bool
+	logoutYes  bool
+	logoutTags []string
+)
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout <name>|--all|--tag <tags>",
+	Short: "Revoke the credentials of a GCloud configuration",
+	Long: `Log out of a configuration: revoke the user account set on its native gcloud
+configuration, revoke and delete its saved ADC, and forget the saved ADC path.
+
+Note that gcloud stores user credentials per account, so revoking an account logs
+out every configuration that uses it.
+
+With --tag, every configuration carrying all of the given tags is logged out.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		selectors := len(args)
+		if logoutAll {
+			selectors++
+		}
+		if len(logoutTags) > 0 {
+			selectors++
+		}
+		if selectors > 1 || len(args) > 1 {
+			return errors.New("use only one of a configuration name, --all or --tag")
+		}
+		if selectors == 0 {
+			return errors.New("requires a configuration name, --all or --tag")
+		}
+		return nil
+	},
+	ValidArgsFunction: GetConfigNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		var targets []*config.GCloudConfig
+		switch {
+		case logoutAll:
+			for i := range store.Configurations {
+				targets = append(targets, &store.Configurations[i])
+			}
+		case len(logoutTags) > 0:
+			targets, err = selectByTags(store, logoutTags)
+			if err != nil {
+				return err
+			}
+		default:
+			cfg, err := resolveConfig(store, args[0])
+			if err != nil {
+				return err
+			}
+			targets = append(targets, cfg)
+		}
+
+		if len(targets) == 0 {
+			logger.Info("No configurations found.")
+			return nil
+		}
+
+		question := fmt.Sprintf("Revoke the credentials of '%s'?", targets[0].Name)
+		if logoutAll {
+			question = fmt.Sprintf("Revoke the credentials of all %d configurations?", len(targets))
+		} else if len(logoutTags) > 0 {
+			question = fmt.Sprintf("Revoke the credentials of %d configurations (%s)?", len(targets), configNames(targets))
+		}
+		if !logoutYes && !dryrun.Enabled() && !confirm(cmd.ErrOrStderr(), question) {
+			logger.Info("Aborted")
+			return nil
+		}
+
+		active, _ := gcloud.GetActiveConfiguration(ctx)
+		active = strings.TrimSpace(active)
+
+		state := config.LoadState()
+		revokedAccounts := make(map[string]bool)
+		// Looked up once, and only when an account is revoked
+		accounts := sync.OnceValue(func() map[string]string { return configurationAccounts(ctx, store) })
+		for _, cfg := range targets {
+			logoutConfiguration(ctx, store, cfg, active, revokedAccounts, accounts)
+			state.RecordCredentials(state.CredentialKey(cfg.Name), false, time.Now(), time.Time{})
+		}
+
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("failed to save changes: %w", err)
+		}
+		if err := state.Save(); err != nil {
+			logger.Debug("Failed to save state", "error", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "Log out of every configuration")
+	logoutCmd.Flags().BoolVarP(&logoutYes, "yes", "y", false, "Do not ask for confirmation")
+	addTagSelectorFlag(logoutCmd, &logoutTags, "Log out of every configuration with these tags")
+}
+
+// logoutConfiguration revokes the account and ADC of a configuration, reporting what was done.
+// Accounts already revoked during this run are skipped; accounts maps the configurations to their account.
+func logoutConfiguration(ctx context.Context, store *config.ConfigStore, cfg *config.GCloudConfig, activeGcloudConfig string, revokedAccounts map[string]bool, accounts func() map[string]string) {
+	logger.Info("Logging out", "name", cfg.Name)
+
+	if gcloud.ConfigurationExists(ctx, cfg.Name) {
+		account, err := gcloud.GetAccountFromConfiguration(ctx, cfg.Name)
+		switch {
+		case err != nil:
+			logger.Warning("  Could not read the account", "error", err)
+		case account == "":
+			logger.Info("  No account set on the native configuration")
+		case revokedAccounts[account]:
+			logger.Info("  Account already revoked", "account", account)
+		default:
+			if err := gcloud.RevokeAccount(ctx, account); err != nil {
+				logger.Warning("  Failed to revoke account", "account", account, "error", err)
+			} else {
+				revokedAccounts[account] = true
+				logger.Success("  Revoked account", "account", account)
+				if shared := configurationsSharingAccount(store, accounts(), account, cfg.Name); len(shared) > 0 {
+					logger.Warning("  Account is also used by: " + strings.Join(shared, ", "))
+				}
+			}
+		}
+	}
+
+	// The live ADC belongs to the active configuration
+	if cfg.Name == activeGcloudConfig {
+		if adcPath, err := gcloud.GetADCPath(); err == nil && !revocableADC(adcPath) {
+			logger.Info("  Impersonated ADC cannot be revoked, deleting it only")
+		} else if err := gcloud.RevokeADC(ctx); err != nil {
+			logger.Warning("  Failed to revoke current ADC", "error", err)
+		} else {
+			logger.Success("  Revoked current ADC")
+		}
+		// Make sure the file is gone even if the revocation failed
+		if err := gcloud.DeleteADC(); err != nil {
+			logger.Warning("  Failed to delete current ADC", "error", err)
+		}
+	}
+
+	if cfg.ADCPath != "" {
+		if _, err := os.Stat(cfg.ADCPath); err == nil {
+			if cfg.Name != activeGcloudConfig {
+				if !revocableADC(cfg.ADCPath) {
+					logger.Info("  Impersonated ADC cannot be revoked, deleting it only")
+				} else if err := gcloud.RevokeADCFile(ctx, cfg.ADCPath); err != nil {
+					logger.Warning("  Failed to revoke saved ADC", "error", err)
+				} else {
+					logger.Success("  Revoked saved ADC")
+				}
+			}
+			if err := config.RemoveFile(cfg.ADCPath); err != nil {
+				logger.Warning("  Failed to delete saved ADC", "error", err)
+			} else {
+				logger.Success("  Deleted saved ADC", "path", cfg.ADCPath)
+			}
+		}
+		// A shared ADC is revoked for every configuration using it
+		if shared := store.ConfigsUsingADC(cfg.ADCPath, cfg.Name); len(shared) > 0 {
+			logger.Warning("  Saved ADC was also used by: " + strings.Join(shared, ", "))
+		}
+		store.ForgetADC(cfg.ADCPath)
+	}
+}
+
+// configurationAccounts maps each configuration to the account set on its native configuration
+func configurationAccounts(ctx context.Context, store *config.ConfigStore) map[string]string {
+	accounts := make(map[string]string)
+	for _, cfg := range store.Configurations {
+		if account, err := gcloud.GetAccountFromConfiguration(ctx, cfg.Name); err == nil && account != "" {
+			accounts[cfg.Name] = account
+		}
+	}
+	return accounts
+}
+
+// configurationsSharingAccount lists the other configurations whose native configuration uses the account
+func configurationsSharingAccount(store *config.ConfigStore, accounts map[string]string, account, excluded string) []string {
+	var shared []string
+	for _, cfg := range store.Configurations {
+		if cfg.Name != excluded && accounts[cfg.Name] == account {
+			shared = append(shared, cfg.Name)
+		}
+	}
+	return shared
+}
+
+// revocableADC reports whether gcloud can revoke the ADC file at path: it rejects
+// impersonated ADC, whose source credentials belong to the account revoked separately
+func revocableADC(path string) bool {
+	identity, err := gcloud.ReadADCIdentityFile(path)
+	return err != nil || identity.Type != gcloud.ADCImpersonated
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/metadata"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metadataPort    int
+	metadataAddress string
+)
+
+var metadataServerCmd = &cobra.Command{
+	Use:   "metadata-server <name>",
+	Short: "Serve the credentials of a configuration as a GCE metadata server",
+	Long: `Emulate the GCE metadata server for tools and containers that only know how to
+get credentials from it. The project ID, numeric project ID, account email, access
+tokens and identity tokens are served from the saved ADC of the configuration, without
+switching to it. Access tokens are renewed shortly before they expire.
+
+Requests must carry the Metadata-Flavor: Google header. The server listens on the
+loopback interface unless --address is given; anyone able to reach it gets tokens.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: GetConfigNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+		cfg, err := resolveConfig(store, args[0])
+		if err != nil {
+			return err
+		}
+
+		server, err := newMetadataServer(ctx, cfg, cfg.Name == store.ActiveConfig)
+		if err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(metadataAddress, strconv.Itoa(metadataPort)))
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		if ip := net.ParseIP(metadataAddress); ip == nil || !ip.IsLoopback() {
+			logger.Warning("The metadata server is reachable from other machines and hands out tokens", "address", metadataAddress)
+		}
+		logger.Success("Serving metadata", "name", cfg.Name, "address", listener.Addr().String(), "email", server.Email)
+		logger.Info("Point client libraries at it with:")
+		for _, env := range metadata.HostEnv(listener.Addr().String()) {
+			logger.Info("  export " + env)
+		}
+		logger.Info("Press Ctrl-C to stop")
+		return server.Serve(ctx, listener)
+	},
+}
+
+// newMetadataServer prepares a metadata server backed by the ADC of cfg, which is the
+// live ADC when cfg is the active configuration
+func newMetadataServer(ctx context.Context, cfg *config.GCloudConfig, active bool) (*metadata.Server, error) {
+	account := cfg.Account
+	if account == "" {
+		account, _ = gcloud.GetAccountFromConfiguration(ctx, cfg.Name)
+	}
+	adcPath := configADCPath(cfg, account, active)
+	if _, err := os.Stat(adcPath); adcPath == "" || err != nil {
+		return nil, fmt.Errorf("configuration '%s' has no saved ADC, switch to it once to log in", cfg.Name)
+	}
+	identity, err := gcloud.ReadADCIdentityFile(adcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	email := cmp.Or(cfg.ServiceAccount, identity.ServiceAccount, account, identity.Account)
+	projectNumber, err := gcloud.GetProjectNumber(ctx, cfg.ProjectID)
+	if err != nil {
+		logger.Warning("Numeric project ID unavailable", "project_id", cfg.ProjectID, "error", err)
+	}
+
+	return &metadata.Server{
+		ProjectID:        cfg.ProjectID,
+		NumericProjectID: projectNumber,
+		Email:            email,
+		Token: func(ctx context.Context) (metadata.Token, error) {
+			token, err := gcloud.PrintADCFileAccessToken(ctx, adcPath)
+			return metadata.Token{AccessToken: token.Token, Expiry: token.Expiry}, err
+		},
+		IdentityToken: func(ctx context.Context, audience string) (string, error) {
+			return gcloud.PrintIdentityToken(ctx, cfg.Name, cfg.ImpersonationChain(), audience)
+		},
+	}, nil
+}
+
+func init() {
+	metadataServerCmd.Flags().IntVar(&metadataPort, "port", 8080, "Port to listen on")
+	metadataServerCmd.Flags().StringVar(&metadataAddress, "address", "127.0.0.1", "Address to listen on, e.g. 0.0.0.0 for containers")
+}
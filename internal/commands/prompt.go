@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/output"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultPromptFormat = `{{.name}}{{if .project}}:{{.project}}{{end}}{{if .protected}} !{{end}}{{if .stale}} ⟳{{end}}`
+
+var promptFormat string
+
+// promptView holds the fields available to prompt templates
+type promptView struct {
+	Name      string `json:"name"`
+	Project   string `json:"project"`
+	Protected bool   `json:"protected"`
+	Stale     bool   `json:"stale"`
+	Tracked   bool   `json:"tracked"`
+}
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a short segment describing the active configuration for shell prompts",
+	Long: `Print the active configuration in a compact form suitable for PS1 or starship.
+
+Only config.json, gcloud's active_config file and cached state are read: no gcloud
+process is spawned, so the command finishes in a few milliseconds. Credentials are
+stale when the last check made by switch or current found them invalid or their
+token has expired since.
+
+The --format flag takes a Go template with the fields name, project, protected,
+stale and tracked (whether gcloud-switcher knows the configuration).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := gcloud.ReadActiveConfigurationName()
+		if err != nil || name == "" {
+			// Never break the prompt
+			return nil //nolint:nilerr
+		}
+
+		view := promptView{Name: name}
+		if store, err := config.LoadConfigStore(); err == nil {
+			if cfg, err := store.FindConfig(name); err == nil {
+				view.Tracked = true
+				view.Project = cfg.ProjectID
+				view.Protected = cfg.Protected
+			}
+		}
+		state := config.LoadState()
+		if credentials, ok := state.Credentials[state.CredentialKey(name)]; ok {
+			view.Stale = !credentials.Fresh(time.Now(), credentialMargin)
+		}
+
+		format := output.Format{Kind: output.Template, Template: promptFormat}
+		return output.Write(cmd.OutOrStdout(), format, view)
+	},
+}
+
+func init() {
+	promptCmd.Flags().StringVar(&promptFormat, "format", defaultPromptFormat, "Go template of the prompt segment")
+}
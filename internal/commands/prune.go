@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// pruneKind identifies what a prune candidate refers to
+type pruneKind int
+
+const (
+	pruneOrphanedADC pruneKind = iota
+	pruneStaleADC
+	pruneNativeConfig
+)
+
+// pruneCandidate is a single piece of cruft that prune can delete
+type pruneCandidate struct {
+	Kind   pruneKind
+	Name   string
+	Path   string
+	Reason string
+}
+
+var (
+	pruneYes       bool
+	pruneOlderThan int
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete orphaned ADC files and native gcloud configurations",
+	Long: `Find and delete the cruft left behind by removed configurations:
+  - saved ADC files without a matching configuration
+  - native gcloud configurations created by gcloud-switcher that are no longer in the store
+  - saved ADC files older than --older-than days (disabled by default)
+
+The candidates are listed before anything is deleted and a confirmation is asked
+unless --yes is given. Use --dry-run to only list them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		ownedBefore := len(store.OwnedConfigurations)
+
+		candidates, err := findADCCandidates(store, pruneOlderThan, time.Now())
+		if err != nil {
+			return err
+		}
+		nativeCandidates, err := findNativeCandidates(ctx, store)
+		if err != nil {
+			logger.Warning("Skipping native gcloud configurations", "error", err)
+		}
+		candidates = append(candidates, nativeCandidates...)
+
+		if len(candidates) == 0 {
+			// Forget ownership markers of native configurations deleted outside of gcloud-switcher
+			if !dryrun.Enabled() && len(store.OwnedConfigurations) != ownedBefore {
+				if err := store.Save(); err != nil {
+					return fmt.Errorf("failed to save changes: %w", err)
+				}
+			}
+			logger.Success("Nothing to prune")
+			return nil
+		}
+
+		logger.Info("The following items will be deleted:")
+		for _, candidate := range candidates {
+			target := candidate.Path
+			if target == "" {
+				target = candidate.Name
+			}
+			logger.Info("  "+target, "reason", candidate.Reason)
+		}
+
+		if !dryrun.Enabled() && !pruneYes && !confirm(cmd.ErrOrStderr(), fmt.Sprintf("Delete %d item(s)?", len(candidates))) {
+			logger.Info("Aborted")
+			return nil
+		}
+
+		deleted := 0
+		for _, candidate := range candidates {
+			if err := pruneCandidateItem(ctx, store, candidate); err != nil {
+				logger.Warning("Failed to prune", "item", candidate.Name, "error", err)
+				continue
+			}
+			deleted++
+		}
+
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("failed to save changes: %w", err)
+		}
+
+		logger.Success("Pruned items", "count", deleted)
+		return nil
+	},
+}
+
+func init() {
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Do not ask for confirmation")
+	pruneCmd.Flags().IntVar(&pruneOlderThan, "older-than", 0, "Also delete saved ADC files older than this many days (0 disables)")
+}
+
+// findADCCandidates lists saved ADC files that are orphaned or older than olderThanDays
+func findADCCandidates(store *config.ConfigStore, olderThanDays int, now time.Time) ([]pruneCandidate, error) {
+	adcDir, err := config.GetADCStoragePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ADC storage path: %w", err)
+	}
+	entries, err := os.ReadDir(adcDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ADC storage: %w", err)
+	}
+
+	var candidates []pruneCandidate
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		path := filepath.Join(adcDir, entry.Name())
+
+		if _, err := store.FindConfig(name); err != nil {
+			candidates = append(candidates, pruneCandidate{Kind: pruneOrphanedADC, Name: name, Path: path, Reason: "no matching configuration"})
+			continue
+		}
+
+		if olderThanDays <= 0 {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		age := now.Sub(info.ModTime())
+		if age > time.Duration(olderThanDays)*24*time.Hour {
+			reason := fmt.Sprintf("not refreshed for %d days", int(age.Hours()/24))
+			candidates = append(candidates, pruneCandidate{Kind: pruneStaleADC, Name: name, Path: path, Reason: reason})
+		}
+	}
+
+	shared, err := findSharedADCCandidates(store, olderThanDays, now)
+	if err != nil {
+		return nil, err
+	}
+	return append(candidates, shared...), nil
+}
+
+// findSharedADCCandidates lists ADC files stored per account that no configuration
+// uses anymore or that are older than olderThanDays
+func findSharedADCCandidates(store *config.ConfigStore, olderThanDays int, now time.Time) ([]pruneCandidate, error) {
+	sharedDir, err := config.GetSharedADCStoragePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ADC storage path: %w", err)
+	}
+	entries, err := os.ReadDir(sharedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ADC storage: %w", err)
+	}
+
+	var candidates []pruneCandidate
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		path := filepath.Join(sharedDir, entry.Name())
+
+		if len(store.ConfigsUsingADC(path, "")) == 0 {
+			candidates = append(candidates, pruneCandidate{Kind: pruneOrphanedADC, Name: name, Path: path, Reason: "no configuration uses this account"})
+			continue
+		}
+
+		if olderThanDays <= 0 {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		age := now.Sub(info.ModTime())
+		if age > time.Duration(olderThanDays)*24*time.Hour {
+			reason := fmt.Sprintf("not refreshed for %d days", int(age.Hours()/24))
+			candidates = append(candidates, pruneCandidate{Kind: pruneStaleADC, Name: name, Path: path, Reason: reason})
+		}
+	}
+	return candidates, nil
+}
+
+// findNativeCandidates lists native gcloud configurations owned by gcloud-switcher but no longer in the store
+func findNativeCandidates(ctx context.Context, store *config.ConfigStore) ([]pruneCandidate, error) {
+	var owned []string
+	for _, name := range store.OwnedConfigurations {
+		if _, err := store.FindConfig(name); err != nil {
+			owned = append(owned, name)
+		}
+	}
+	if len(owned) == 0 {
+		return nil, nil
+	}
+
+	native, err := gcloud.ListConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(native))
+	for _, name := range native {
+		existing[name] = true
+	}
+
+	active, _ := gcloud.GetActiveConfiguration(ctx)
+	active = strings.TrimSpace(active)
+
+	var candidates []pruneCandidate
+	for _, name := range owned {
+		if !existing[name] {
+			// Already gone, only the ownership marker is left
+			store.Disown(name)
+			continue
+		}
+		if name == active {
+			logger.Warning("Skipping active native gcloud configuration", "name", name)
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{Kind: pruneNativeConfig, Name: name, Reason: "native configuration created by gcloud-switcher"})
+	}
+	return candidates, nil
+}
+
+func pruneCandidateItem(ctx context.Context, store *config.ConfigStore, candidate pruneCandidate) error {
+	switch candidate.Kind {
+	case pruneNativeConfig:
+		if err := gcloud.DeleteConfiguration(ctx, candidate.Name); err != nil {
+			return err
+		}
+		store.Disown(candidate.Name)
+	case pruneStaleADC:
+		if err := config.RemoveFile(candidate.Path); err != nil {
+			return err
+		}
+		store.ForgetADC(candidate.Path)
+	case pruneOrphanedADC:
+		if err := config.RemoveFile(candidate.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	refreshAll    bool
+	refreshTags   []string
+	refreshWithin time.Duration
+)
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh <name>|--all|--tag <tags>",
+	Short: "Renew invalid or expiring credentials ahead of time",
+	Long: `Check the credentials of the selected configurations and log in again for
+those that are invalid or expire within --within, as 'switch' would. The new ADC
+is saved for each configuration, then the originally active configuration and
+its ADC are put back.
+
+Configurations sharing an account are refreshed with a single login.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		selectors := len(args)
+		if refreshAll {
+			selectors++
+		}
+		if len(refreshTags) > 0 {
+			selectors++
+		}
+		if selectors > 1 || len(args) > 1 {
+			return errors.New("use only one of a configuration name, --all or --tag")
+		}
+		if selectors == 0 {
+			return errors.New("requires a configuration name, --all or --tag")
+		}
+		return nil
+	},
+	ValidArgsFunction: GetConfigNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		var targets []*config.GCloudConfig
+		switch {
+		case refreshAll:
+			targets = store.SelectByTags(nil)
+		case len(refreshTags) > 0:
+			targets, err = selectByTags(store, refreshTags)
+			if err != nil {
+				return err
+			}
+		default:
+			cfg, err := resolveConfig(store, args[0])
+			if err != nil {
+				return err
+			}
+			targets = append(targets, cfg)
+		}
+		if len(targets) == 0 {
+			logger.Info("No configurations found.")
+			return nil
+		}
+
+		state := config.LoadState()
+		logger.Info("Checking credentials...", "configurations", len(targets))
+		statuses := checkConfigStatuses(ctx, store, state, targets, defaultStatusWorkers, false)
+
+		var stale []*config.GCloudConfig
+		deadline := time.Now().Add(refreshWithin)
+		for i, status := range statuses {
+			if status.NeedsLogin || status.ExpiresAt == nil || status.ExpiresAt.Before(deadline) {
+				stale = append(stale, targets[i])
+			}
+		}
+		if len(stale) == 0 {
+			logger.Success("All credentials are valid", "for_at_least", refreshWithin)
+			return state.Save()
+		}
+		logger.Info("Credentials to renew: " + configNames(stale))
+
+		refreshed, err := refreshConfigurations(ctx, store, state, stale)
+		if saveErr := store.Save(); saveErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to save configuration: %w", saveErr))
+		}
+		if saveErr := state.Save(); saveErr != nil {
+			logger.Debug("Failed to save state", "error", saveErr)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Success("Refreshed credentials", "configurations", refreshed)
+		return nil
+	},
+}
+
+// refreshConfigurations logs in again for each configuration in turn, then puts back the
+// originally active gcloud configuration and ADC even when a login failed or was cancelled
+func refreshConfigurations(ctx context.Context, store *config.ConfigStore, state *config.State, configs []*config.GCloudConfig) (int, error) {
+	previousGcloudConfig, _ := gcloud.GetActiveConfiguration(ctx)
+	previousGcloudConfig = strings.TrimSpace(previousGcloudConfig)
+	previousADC, err := gcloud.SnapshotADC()
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	activeRefreshed := false
+	done := make(map[string]string)
+	for _, cfg := range configs {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		// One login renews every configuration with the same credential key
+		key := state.CredentialKey(cfg.Name)
+		if account := cfg.Account; account != "" {
+			key = cfg.CredentialKey(account)
+		} else if account, _ := gcloud.GetAccountFromConfiguration(ctx, cfg.Name); account != "" {
+			key = cfg.CredentialKey(account)
+		}
+		if adcPath, ok := done[key]; ok {
+			logger.Info("Already refreshed with the same account", "name", cfg.Name)
+			if config.IsSharedADC(adcPath) {
+				cfg.ADCPath = adcPath
+			}
+			refreshed++
+			continue
+		}
+		if !gcloud.ConfigurationExists(ctx, cfg.Name) {
+			logger.Warning("Skipping configuration without a native gcloud configuration, switch to it once to create it", "name", cfg.Name)
+			continue
+		}
+
+		logger.Info("Refreshing credentials", "name", cfg.Name)
+		if err := gcloud.ActivateConfiguration(ctx, cfg.Name); err != nil {
+			err = fmt.Errorf("failed to refresh '%s': %w", cfg.Name, err)
+			return refreshed, errors.Join(err, restoreActive(ctx, previousGcloudConfig, previousADC, ""))
+		}
+		if err := loginConfiguration(ctx, state, cfg); err != nil {
+			err = fmt.Errorf("failed to refresh '%s': %w", cfg.Name, err)
+			return refreshed, errors.Join(err, restoreActive(ctx, previousGcloudConfig, previousADC, ""))
+		}
+		done[key] = cfg.ADCPath
+		done[state.CredentialKey(cfg.Name)] = cfg.ADCPath
+		activeRefreshed = activeRefreshed || cfg.Name == store.ActiveConfig
+		refreshed++
+	}
+
+	// The renewed ADC of the active configuration replaces the one it had
+	activeADC := ""
+	if activeRefreshed {
+		if cfg, err := store.FindConfig(store.ActiveConfig); err == nil {
+			activeADC = cfg.ADCPath
+		}
+	}
+	return refreshed, errors.Join(ctx.Err(), restoreActive(ctx, previousGcloudConfig, previousADC, activeADC))
+}
+
+// restoreActive reactivates a gcloud configuration and puts back its ADC, from adcPath
+// when set or else from the snapshot; it runs even when ctx is cancelled
+func restoreActive(ctx context.Context, gcloudConfig string, snapshot gcloud.ADCSnapshot, adcPath string) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	if gcloudConfig != "" {
+		if err := gcloud.ActivateConfiguration(ctx, gcloudConfig); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if adcPath != "" {
+		errs = append(errs, gcloud.RestoreADC(adcPath))
+	} else {
+		errs = append(errs, snapshot.Restore())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to return to configuration '%s': %w", gcloudConfig, err)
+	}
+	if gcloudConfig != "" {
+		logger.Info("Returned to configuration", "name", gcloudConfig)
+	}
+	return nil
+}
+
+func init() {
+	refreshCmd.Flags().BoolVar(&refreshAll, "all", false, "Refresh every configuration")
+	refreshCmd.Flags().DurationVar(&refreshWithin, "within", credentialMargin, "Also renew valid credentials expiring within this duration")
+	addTagSelectorFlag(refreshCmd, &refreshTags, "Refresh every configuration with these tags")
+}
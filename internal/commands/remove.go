@@ -1,49 +1,195 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/gcloud"
 	"gcloud-switch/internal/logger"
-	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	removePurge bool
+	removeYes   bool
+	removeTags  []string
+)
+
 var removeCmd = &cobra.Command{
-	Use:               "remove <name>",
-	Short:             "Remove an existing GCloud configuration",
-	Long:              `Delete a configuration from the configuration store.`,
-	Args:              cobra.ExactArgs(1),
+	Use:   "remove <name>|--tag <tags>",
+	Short: "Remove an existing GCloud configuration",
+	Long: `Delete a configuration from the configuration store.
+
+With --purge, the native gcloud configuration is deleted as well. If it is the
+active gcloud configuration, another one is activated first.
+
+With --tag, every configuration carrying all of the given tags is removed after
+confirmation.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(removeTags) > 0 {
+			if len(args) > 0 {
+				return errors.New("cannot combine a configuration name with --tag")
+			}
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	ValidArgsFunction: GetConfigNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configName := args[0]
+		ctx := cmd.Context()
 
 		store, err := config.LoadConfigStore()
 		if err != nil {
 			return fmt.Errorf("failed to load configurations: %w", err)
 		}
 
-		// Get the config to check for saved ADC
-		cfg, err := store.FindConfig(configName)
-		if err == nil && cfg.ADCPath != "" {
-			// Clean up saved ADC file
-			if err := os.Remove(cfg.ADCPath); err != nil && !os.IsNotExist(err) {
-				logger.Warning("Failed to remove saved ADC file", "error", err)
+		var names []string
+		var question string
+		if len(removeTags) > 0 {
+			targets, err := selectByTags(store, removeTags)
+			if err != nil {
+				return err
+			}
+			for _, cfg := range targets {
+				names = append(names, cfg.Name)
+			}
+			question = fmt.Sprintf("Remove %d configurations (%s)?", len(names), strings.Join(names, ", "))
+			if removePurge {
+				question = fmt.Sprintf("Remove %d configurations (%s) and delete their native gcloud configurations?", len(names), strings.Join(names, ", "))
+			}
+		} else {
+			cfg, err := resolveConfig(store, args[0])
+			if err != nil {
+				return err
+			}
+			names = []string{cfg.Name}
+			if removePurge {
+				question = fmt.Sprintf("Remove '%s' and delete its native gcloud configuration?", cfg.Name)
+			} else if cfg.Name != args[0] {
+				// Never remove a configuration matched by an alias or prefix without asking
+				question = fmt.Sprintf("Remove '%s'?", cfg.Name)
 			}
 		}
 
-		if err := store.RemoveConfig(configName); err != nil {
-			return fmt.Errorf("failed to remove configuration: %w", err)
+		if question != "" && !removeYes && !dryrun.Enabled() && !confirm(cmd.ErrOrStderr(), question) {
+			logger.Info("Aborted")
+			return nil
+		}
+
+		state := config.LoadState()
+		for _, configName := range names {
+			if err := removeConfiguration(ctx, store, configName); err != nil {
+				return err
+			}
+			state.ForgetCredentials(configName)
 		}
 
 		if err := store.Save(); err != nil {
 			return fmt.Errorf("failed to save changes: %w", err)
 		}
+		if err := state.Save(); err != nil {
+			logger.Debug("Failed to save state", "error", err)
+		}
 
-		// Note: We don't delete the native gcloud configuration as the user might want to keep it
-		// They can manually delete it with: gcloud config configurations delete <name>
-		logger.Success("Successfully removed configuration", "name", configName)
-		logger.Info("Note: Native gcloud configuration still exists. Delete manually if needed with: gcloud config configurations delete " + configName)
+		for _, configName := range names {
+			logger.Success("Successfully removed configuration", "name", configName)
+			if removePurge {
+				logger.Success("Native gcloud configuration deleted", "name", configName)
+			} else {
+				// Note: We don't delete the native gcloud configuration as the user might want to keep it
+				logger.Info("Note: Native gcloud configuration still exists. Delete it with 'gcloud-switcher remove --purge', 'gcloud-switcher prune' or manually with: gcloud config configurations delete " + configName)
+			}
+		}
 		return nil
 	},
 }
+
+// removeConfiguration removes a configuration and its saved ADC from the store,
+// deleting the native gcloud configuration too with --purge
+func removeConfiguration(ctx context.Context, store *config.ConfigStore, configName string) error {
+	// Get the config to check for saved ADC
+	cfg, err := store.FindConfig(configName)
+	// Shared ADC files are kept while other configurations use them
+	if err == nil && cfg.ADCPath != "" && len(store.ConfigsUsingADC(cfg.ADCPath, cfg.Name)) == 0 {
+		// Clean up saved ADC file
+		if err := config.RemoveFile(cfg.ADCPath); err != nil {
+			logger.Warning("Failed to remove saved ADC file", "error", err)
+		}
+	}
+
+	if err := store.RemoveConfig(configName); err != nil {
+		return fmt.Errorf("failed to remove configuration: %w", err)
+	}
+
+	if removePurge && gcloud.ConfigurationExists(ctx, configName) {
+		if err := purgeNativeConfiguration(ctx, store, configName); err != nil {
+			return err
+		}
+		store.Disown(configName)
+	}
+	return nil
+}
+
+func init() {
+	removeCmd.Flags().BoolVar(&removePurge, "purge", false, "Also delete the native gcloud configuration")
+	removeCmd.Flags().BoolVarP(&removeYes, "yes", "y", false, "Do not ask for confirmation")
+	addTagSelectorFlag(removeCmd, &removeTags, "Remove every configuration with these tags")
+}
+
+// purgeNativeConfiguration deletes a native gcloud configuration, activating another one first if needed
+func purgeNativeConfiguration(ctx context.Context, store *config.ConfigStore, configName string) error {
+	active, _ := gcloud.GetActiveConfiguration(ctx)
+	if strings.TrimSpace(active) == configName {
+		fallback, err := fallbackConfiguration(ctx, store, configName)
+		if err != nil {
+			return err
+		}
+		logger.Info("Activating another gcloud configuration before deletion", "name", fallback)
+		if err := gcloud.ActivateConfiguration(ctx, fallback); err != nil {
+			return err
+		}
+		if fallback != store.ActiveConfig {
+			store.ActiveConfig = ""
+		}
+	}
+
+	if err := gcloud.DeleteConfiguration(ctx, configName); err != nil {
+		return fmt.Errorf("failed to delete native gcloud configuration: %w", err)
+	}
+	return nil
+}
+
+// fallbackConfiguration picks the native configuration to activate when the active one is deleted,
+// preferring the tracked active configuration, then gcloud's default one
+func fallbackConfiguration(ctx context.Context, store *config.ConfigStore, excluded string) (string, error) {
+	native, err := gcloud.ListConfigurations(ctx)
+	if err != nil {
+		return "", err
+	}
+	existing := make(map[string]bool, len(native))
+	for _, name := range native {
+		existing[name] = true
+	}
+
+	if store.ActiveConfig != "" && store.ActiveConfig != excluded && existing[store.ActiveConfig] {
+		return store.ActiveConfig, nil
+	}
+	if excluded != "default" && existing["default"] {
+		return "default", nil
+	}
+	for _, name := range native {
+		if name != excluded {
+			return name, nil
+		}
+	}
+
+	// gcloud needs at least one configuration
+	if err := gcloud.CreateConfiguration(ctx, "default"); err != nil {
+		return "", err
+	}
+	return "default", nil
+}
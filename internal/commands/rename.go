@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a GCloud configuration",
+	Long: `Rename a configuration everywhere: in the configuration store, the saved ADC
+file and the native gcloud configuration (recreated under the new name with all
+its properties). If any step fails, the completed steps are rolled back.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// Only the old name can be completed
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetConfigNames(cmd, args, toComplete)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		oldName, newName := args[0], args[1]
+
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+
+		cfg, err := resolveConfig(store, oldName)
+		if err != nil {
+			return err
+		}
+		oldName = cfg.Name
+		if store.NameInUse(newName, "") {
+			return fmt.Errorf("configuration or alias '%s' already exists", newName)
+		}
+		if gcloud.ConfigurationExists(ctx, newName) {
+			return fmt.Errorf("a native gcloud configuration named '%s' already exists", newName)
+		}
+
+		logger.Info("Renaming configuration", "from", oldName, "to", newName)
+
+		var steps []step
+
+		if gcloud.ConfigurationExists(ctx, oldName) {
+			properties, err := gcloud.GetConfigurationProperties(ctx, oldName)
+			if err != nil {
+				return err
+			}
+			steps = append(steps, renameNativeSteps(ctx, oldName, newName, properties)...)
+		}
+
+		oldADCPath := cfg.ADCPath
+		newADCPath, err := config.GetADCFileForConfig(newName)
+		if err != nil {
+			return err
+		}
+		adcMoved := false
+		// A shared ADC is stored per account and stays where it is
+		adcShared := config.IsSharedADC(oldADCPath)
+		if oldADCPath != "" && !adcShared {
+			if _, err := os.Stat(oldADCPath); err == nil {
+				adcMoved = true
+				steps = append(steps, step{
+					Name: "move saved ADC",
+					Do:   func(ctx context.Context) error { return config.MoveFile(oldADCPath, newADCPath) },
+					Undo: func(ctx context.Context) error { return config.MoveFile(newADCPath, oldADCPath) },
+				})
+			}
+		}
+
+		steps = append(steps, step{
+			Name: "update configuration store",
+			Do: func(ctx context.Context) error {
+				if err := store.RenameConfig(oldName, newName); err != nil {
+					return err
+				}
+				renamed, err := store.FindConfig(newName)
+				if err != nil {
+					return err
+				}
+				switch {
+				case adcShared:
+				case adcMoved:
+					renamed.ADCPath = newADCPath
+				default:
+					renamed.ADCPath = ""
+				}
+				return store.Save()
+			},
+		})
+
+		if err := runSteps(ctx, steps); err != nil {
+			return fmt.Errorf("failed to rename configuration: %w", err)
+		}
+
+		state := config.LoadState()
+		state.RenameCredentials(oldName, newName)
+		if err := state.Save(); err != nil {
+			logger.Debug("Failed to save state", "error", err)
+		}
+
+		logger.Success("Successfully renamed configuration", "from", oldName, "to", newName)
+		return nil
+	},
+}
+
+// renameNativeSteps recreates a native gcloud configuration under a new name and deletes the old one
+func renameNativeSteps(ctx context.Context, oldName, newName string, properties map[string]string) []step {
+	active, _ := gcloud.GetActiveConfiguration(ctx)
+	wasActive := strings.TrimSpace(active) == oldName
+
+	steps := []step{{
+		Name: "create native configuration " + newName,
+		Do:   func(ctx context.Context) error { return gcloud.CopyConfiguration(ctx, newName, properties) },
+		Undo: func(ctx context.Context) error { return gcloud.DeleteConfiguration(ctx, newName) },
+	}}
+
+	// gcloud refuses to delete the active configuration
+	if wasActive {
+		steps = append(steps, step{
+			Name: "activate native configuration " + newName,
+			Do:   func(ctx context.Context) error { return gcloud.ActivateConfiguration(ctx, newName) },
+			Undo: func(ctx context.Context) error { return gcloud.ActivateConfiguration(ctx, oldName) },
+		})
+	}
+
+	steps = append(steps, step{
+		Name: "delete native configuration " + oldName,
+		Do:   func(ctx context.Context) error { return gcloud.DeleteConfiguration(ctx, oldName) },
+		Undo: func(ctx context.Context) error { return gcloud.CopyConfiguration(ctx, oldName, properties) },
+	})
+	return steps
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/logger"
+)
+
+// resolveConfig finds the configuration named by a command argument, accepting
+// aliases and unique prefixes, and reports when the argument was not the exact name
+func resolveConfig(store *config.ConfigStore, query string) (*config.GCloudConfig, error) {
+	cfg, err := store.ResolveConfig(query)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Name != query {
+		logger.Info(fmt.Sprintf("Using configuration '%s' for '%s'", cfg.Name, query))
+	}
+	return cfg, nil
+}
+
+// checkAliases makes sure the aliases of a configuration clash with no other name or alias
+func checkAliases(store *config.ConfigStore, name string, aliases []string) error {
+	for _, alias := range aliases {
+		if alias == name || store.NameInUse(alias, name) {
+			return fmt.Errorf("alias '%s' is already used as a configuration name or alias", alias)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,32 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"gcloud-switch/internal/dryrun"
+	"gcloud-switch/internal/logger"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	dryRun     bool
+	outputFlag string
+	quiet      bool
+	verbose    bool
+	logFormat  string
+
+	noBrowser       bool
+	noLaunchBrowser bool
+)
+
+// stdoutDataAnnotation marks commands whose stdout is data even in text mode, e.g. for $(...)
+const stdoutDataAnnotation = "stdout-data"
+
 var rootCmd = &cobra.Command{
 	Use:   "gcloud-switcher",
 	Short: "A CLI tool to simplify switching between GCloud configurations",
@@ -14,16 +34,92 @@ var rootCmd = &cobra.Command{
 switch between them effortlessly. Instead of manually running multiple 
 gcloud commands, you can define configurations with project IDs and 
 optional service accounts, then switch between them with a single command.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := configureLogger(); err != nil {
+			return err
+		}
+		if dryRun {
+			dryrun.Enable()
+		}
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+		// Machine-readable output owns stdout, so messages go to stderr
+		logOut := cmd.OutOrStdout()
+		if !format.IsText() || cmd.Annotations[stdoutDataAnnotation] != "" {
+			logOut = cmd.ErrOrStderr()
+		}
+		logger.Default().SetOutput(logOut, cmd.ErrOrStderr())
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	// Cancel running gcloud invocations on Ctrl-C or termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
+	if dryrun.Enabled() {
+		printPlan(rootCmd.ErrOrStderr())
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err) //nolint:errcheck
+		stop()
 		os.Exit(1)
 	}
 }
+
+// configureLogger applies the global verbosity and log format flags
+func configureLogger() error {
+	log := logger.Default()
+	switch {
+	case quiet:
+		log.SetLevel(logger.LevelWarning)
+	case verbose:
+		log.SetLevel(logger.LevelDebug)
+	default:
+		log.SetLevel(logger.LevelInfo)
+	}
+
+	switch logFormat {
+	case "text":
+		log.SetJSON(false)
+	case "json":
+		log.SetJSON(true)
+	default:
+		return fmt.Errorf("unknown log format '%s' (expected text or json)", logFormat)
+	}
+	return nil
+}
+
+// printPlan prints the operations recorded in dry-run mode to w. The plan is the
+// output of a dry run, so it is printed whatever the log level.
+func printPlan(w io.Writer) {
+	operations := dryrun.Operations()
+	if len(operations) == 0 {
+		_, _ = fmt.Fprintln(w, "Dry run: no changes would be made") //nolint:errcheck
+		return
+	}
+	_, _ = fmt.Fprintln(w, "Dry run: the following operations would be performed") //nolint:errcheck
+	for i, operation := range operations {
+		_, _ = fmt.Fprintf(w, "  %d. %s\n", i+1, operation) //nolint:errcheck
+	}
+}
+
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the planned gcloud invocations and file operations without executing them")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print warnings and errors")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Also print debug messages")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text, or json for one JSON object per line")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", "", "Output format for list, current, status, token and adc inspect: json, yaml, table or template=<go template>")
+	rootCmd.PersistentFlags().BoolVar(&noBrowser, "no-browser", false, "Log in without a browser on this machine, finishing the flow on a second machine with gcloud")
+	rootCmd.PersistentFlags().BoolVar(&noLaunchBrowser, "no-launch-browser", false, "Log in by opening the printed URL in any browser and pasting back the code")
+	rootCmd.MarkFlagsMutuallyExclusive("no-browser", "no-launch-browser")
+
 	// Add all subcommands here
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(switchCmd)
@@ -32,4 +128,16 @@ func init() {
 	rootCmd.AddCommand(removeCmd)
 	rootCmd.AddCommand(currentCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(cloneCmd)
+	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(promptCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(refreshCmd)
+	rootCmd.AddCommand(metadataServerCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(adcCmd)
 }
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/output"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultStatusWorkers bounds the configurations checked at the same time, as each
+// check runs two gcloud processes
+const defaultStatusWorkers = 4
+
+var (
+	statusWorkers    int
+	statusRevalidate bool
+	statusTags       []string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the credential health of every configuration",
+	Long: `Check the account and saved ADC credentials of every configuration, without
+switching to any of them, and show which ones will need a new login.
+
+The checks run in parallel, at most --parallel at a time. A previous successful
+check is trusted until shortly before its tokens expire unless --revalidate is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+		if statusWorkers < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+		configs := store.SelectByTags(nil)
+		if len(statusTags) > 0 {
+			if configs, err = selectByTags(store, statusTags); err != nil {
+				return err
+			}
+		}
+
+		state := config.LoadState()
+		view := checkConfigStatuses(ctx, store, state, configs, statusWorkers, statusRevalidate)
+		if err := state.Save(); err != nil {
+			logger.Debug("Failed to save state", "error", err)
+		}
+
+		if !format.IsText() {
+			return output.Write(cmd.OutOrStdout(), format, view)
+		}
+		if len(view) == 0 {
+			logger.Info("No configurations found. Use 'gcloud-switcher add' to create one.")
+			return nil
+		}
+		if err := output.Write(cmd.OutOrStdout(), output.Format{Kind: output.Table}, view); err != nil {
+			return err
+		}
+
+		var stale []string
+		for _, status := range view {
+			if status.NeedsLogin {
+				stale = append(stale, status.Name)
+			}
+		}
+		if len(stale) > 0 {
+			logger.Warning("Configurations needing a new login: " + strings.Join(stale, ", "))
+		} else {
+			logger.Success("All credentials are valid")
+		}
+		return nil
+	},
+}
+
+// statusResult is the outcome of checking one configuration
+type statusResult struct {
+	view      statusView
+	key       string
+	checked   bool
+	expiresAt time.Time
+}
+
+// checkConfigStatuses checks the credentials of configs with at most workers checks at a
+// time, recording the outcome of real checks in state
+func checkConfigStatuses(ctx context.Context, store *config.ConfigStore, state *config.State, configs []*config.GCloudConfig, workers int, revalidate bool) statusListView {
+	results := make([]statusResult, len(configs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(configs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				cfg := configs[i]
+				key := state.CredentialKey(cfg.Name)
+				results[i] = checkConfigStatus(ctx, cfg, cfg.Name == store.ActiveConfig, !revalidate && state.Credentials[key].Fresh(time.Now(), credentialMargin))
+				results[i].key = key
+			}
+		}()
+	}
+	for i := range configs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	now := time.Now()
+	view := make(statusListView, 0, len(results))
+	for i, result := range results {
+		if result.checked {
+			state.RecordCredentials(result.key, result.view.AccountValid && result.view.ADCValid, now, result.expiresAt)
+		}
+		if lastUsed, ok := state.LastUsed[result.view.Name]; ok {
+			result.view.LastUsed = &lastUsed
+		}
+		credentials := state.Credentials[result.key]
+		// Credentials obtained without the configured scopes or OAuth client are not reused
+		if lacksADCSettings(credentials, configs[i]) {
+			result.view.NeedsLogin = true
+		}
+		if !credentials.LoggedInAt.IsZero() {
+			result.view.LastLogin = &credentials.LoggedInAt
+		}
+		if credentials.Valid && !credentials.ExpiresAt.IsZero() {
+			result.view.ExpiresAt = &credentials.ExpiresAt
+		}
+		view = append(view, result.view)
+	}
+	return view
+}
+
+// checkConfigStatus checks the account and ADC of a configuration. The active configuration
+// uses the live ADC, the others their saved ADC; cached skips the gcloud calls.
+func checkConfigStatus(ctx context.Context, cfg *config.GCloudConfig, active, cached bool) statusResult {
+	result := statusResult{view: statusView{Name: cfg.Name, Active: active, Account: cfg.Account}}
+	if result.view.Account == "" {
+		result.view.Account, _ = gcloud.GetAccountFromConfiguration(ctx, cfg.Name)
+	}
+
+	adcPath := configADCPath(cfg, result.view.Account, active)
+	result.view.CredentialType = credentialTypeNone
+	if identity, err := gcloud.ReadADCIdentityFile(adcPath); err == nil {
+		result.view.CredentialType = credentialType(identity.Type)
+	}
+
+	if cached {
+		result.view.AccountValid, result.view.ADCValid = true, true
+		return result
+	}
+
+	// The two checks of a configuration are independent, like in validateCredentials
+	var (
+		inner                  sync.WaitGroup
+		accountToken, adcToken gcloud.AccessToken
+		accountErr, adcErr     error
+	)
+	inner.Add(2)
+	go func() {
+		defer inner.Done()
+		accountToken, accountErr = gcloud.PrintAccessTokenForConfiguration(ctx, cfg.Name)
+	}()
+	go func() {
+		defer inner.Done()
+		if result.view.CredentialType == credentialTypeNone {
+			adcErr = fmt.Errorf("no saved ADC")
+			return
+		}
+		adcToken, adcErr = gcloud.PrintADCFileAccessToken(ctx, adcPath)
+	}()
+	inner.Wait()
+
+	result.checked = true
+	result.view.AccountValid, result.view.ADCValid = accountErr == nil, adcErr == nil
+	result.view.NeedsLogin = !result.view.AccountValid || !result.view.ADCValid
+	if !result.view.NeedsLogin {
+		result.expiresAt = accountToken.Expiry
+		if adcToken.Expiry.Before(result.expiresAt) {
+			result.expiresAt = adcToken.Expiry
+		}
+	}
+	return result
+}
+
+func init() {
+	statusCmd.Flags().IntVarP(&statusWorkers, "parallel", "j", defaultStatusWorkers, "Number of configurations checked at the same time")
+	statusCmd.Flags().BoolVar(&statusRevalidate, "revalidate", false, "Check the credentials with gcloud even if a cached check is still valid")
+	addTagSelectorFlag(statusCmd, &statusTags, "Only check the configurations carrying all of these tags")
+}
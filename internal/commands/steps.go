@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/logger"
+)
+
+// errRollbackIncomplete marks errors of runSteps after which some steps could not be undone
+var errRollbackIncomplete = errors.New("rollback incomplete")
+
+// step is a reversible unit of work; Undo may be nil when there is nothing to revert
+type step struct {
+	Name string
+	Do   func(ctx context.Context) error
+	Undo func(ctx context.Context) error
+}
+
+// runSteps executes the steps in order. When one fails or ctx is cancelled, the already
+// completed steps are undone in reverse order and the original error is returned, joined
+// with an errRollbackIncomplete error when some of them could not be undone.
+func runSteps(ctx context.Context, steps []step) error {
+	for i, s := range steps {
+		err := ctx.Err()
+		if err == nil {
+			err = s.Do(ctx)
+		}
+		if err != nil {
+			logger.Error("Step failed", "step", s.Name, "error", err)
+			return errors.Join(fmt.Errorf("%s: %w", s.Name, err), rollbackSteps(ctx, steps[:i]))
+		}
+	}
+	return nil
+}
+
+// rollbackSteps undoes the given completed steps in reverse order, reporting each of them.
+// The undo runs even when ctx was cancelled, e.g. by Ctrl-C.
+func rollbackSteps(ctx context.Context, completed []step) error {
+	ctx = context.WithoutCancel(ctx)
+	var failed []error
+	for i := len(completed) - 1; i >= 0; i-- {
+		s := completed[i]
+		if s.Undo == nil {
+			continue
+		}
+		if err := s.Undo(ctx); err != nil {
+			logger.Warning("Failed to roll back", "step", s.Name, "error", err)
+			failed = append(failed, fmt.Errorf("undo %s: %w", s.Name, err))
+		} else {
+			logger.Info("Rolled back", "step", s.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %w", errRollbackIncomplete, errors.Join(failed...))
+	}
+	return nil
+}
@@ -1,14 +1,22 @@
 package commands
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"gcloud-switch/internal/config"
 	"gcloud-switch/internal/gcloud"
 	"gcloud-switch/internal/logger"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var switchRevalidate bool
+
 var switchCmd = &cobra.Command{
 	Use:   "switch <name>",
 	Short: "Switch to the specified GCloud configuration",
@@ -17,6 +25,7 @@ configuration and handle authentication automatically, reusing stored credential
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: GetConfigNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
 		configName := args[0]
 
 		store, err := config.LoadConfigStore()
@@ -24,108 +33,301 @@ configuration and handle authentication automatically, reusing stored credential
 			return fmt.Errorf("failed to load configurations: %w", err)
 		}
 
-		cfg, err := store.FindConfig(configName)
+		cfg, err := resolveConfig(store, configName)
 		if err != nil {
-			return fmt.Errorf("configuration '%s' not found", configName)
+			return err
 		}
+		configName = cfg.Name
 
 		logger.Info("Switching to configuration", "name", cfg.Name, "project_id", cfg.ProjectID)
 
+		// Remember what has to be put back if the switch fails or is interrupted
+		previousGcloudConfig, _ := gcloud.GetActiveConfiguration(ctx)
+		previousGcloudConfig = strings.TrimSpace(previousGcloudConfig)
+		previousADC, err := gcloud.SnapshotADC()
+		if err != nil {
+			return err
+		}
+
+		state := config.LoadState()
+		var steps []step
+
 		// Step 1: Save ADC of current active configuration (if any)
 		if store.ActiveConfig != "" && store.ActiveConfig != configName {
 			currentCfg, err := store.FindConfig(store.ActiveConfig)
 			if err == nil {
-				adcPath, err := config.GetADCFileForConfig(store.ActiveConfig)
+				adcPath := currentCfg.ADCPath
+				if adcPath == "" {
+					adcPath, err = config.GetADCFileForConfig(store.ActiveConfig)
+				}
 				if err == nil {
-					logger.Info("Saving ADC for current configuration", "name", store.ActiveConfig)
-					if err := gcloud.SaveADC(adcPath); err != nil {
-						logger.Warning("Failed to save ADC", "error", err)
-					} else {
-						currentCfg.ADCPath = adcPath
-					}
+					steps = append(steps, step{
+						Name: "save ADC of " + store.ActiveConfig,
+						Do: func(ctx context.Context) error {
+							logger.Info("Saving ADC for current configuration", "name", store.ActiveConfig)
+							if err := gcloud.SaveADC(adcPath); err != nil {
+								logger.Warning("Failed to save ADC", "error", err)
+							} else {
+								currentCfg.ADCPath = adcPath
+							}
+							return nil
+						},
+					})
 				}
 			}
 		}
 
 		// Step 2: Ensure gcloud configuration exists, create if not
-		if !gcloud.ConfigurationExists(configName) {
-			logger.Info("Creating new gcloud configuration", "name", configName)
-			if err := gcloud.CreateConfiguration(configName); err != nil {
-				return fmt.Errorf("failed to create gcloud configuration: %w", err)
-			}
+		previousProject, previousAccount := "", ""
+		if gcloud.ConfigurationExists(ctx, configName) {
+			previousProject, _ = gcloud.GetProjectFromConfiguration(ctx, configName)
+			previousAccount, _ = gcloud.GetAccountFromConfiguration(ctx, configName)
+		} else {
+			steps = append(steps, step{
+				Name: "create gcloud configuration " + configName,
+				Do: func(ctx context.Context) error {
+					logger.Info("Creating new gcloud configuration", "name", configName)
+					if err := gcloud.CreateConfiguration(ctx, configName); err != nil {
+						return fmt.Errorf("failed to create gcloud configuration: %w", err)
+					}
+					store.MarkOwned(configName)
+					return nil
+				},
+				Undo: func(ctx context.Context) error {
+					store.Disown(configName)
+					return gcloud.DeleteConfiguration(ctx, configName)
+				},
+			})
 		}
 
 		// Step 3: Activate the gcloud configuration
-		logger.Info("Activating gcloud configuration", "name", configName)
-		if err := gcloud.ActivateConfiguration(configName); err != nil {
-			return err
+		steps = append(steps, step{
+			Name: "activate gcloud configuration " + configName,
+			Do: func(ctx context.Context) error {
+				logger.Info("Activating gcloud configuration", "name", configName)
+				if err := gcloud.ActivateConfiguration(ctx, configName); err != nil {
+					return err
+				}
+				logger.Success("Configuration activated")
+				return nil
+			},
+			Undo: func(ctx context.Context) error {
+				if previousGcloudConfig == "" || previousGcloudConfig == configName {
+					return nil
+				}
+				return gcloud.ActivateConfiguration(ctx, previousGcloudConfig)
+			},
+		})
+
+		// Bind the configured account, so credentials are checked for the right one
+		if cfg.Account != "" && cfg.Account != previousAccount {
+			steps = append(steps, step{
+				Name: "set account " + cfg.Account,
+				Do: func(ctx context.Context) error {
+					return gcloud.SetConfigurationProperty(ctx, configName, "core/account", cfg.Account)
+				},
+				Undo: func(ctx context.Context) error {
+					if previousAccount == "" {
+						return gcloud.UnsetConfigurationProperty(ctx, configName, "core/account")
+					}
+					return gcloud.SetConfigurationProperty(ctx, configName, "core/account", previousAccount)
+				},
+			})
 		}
-		logger.Success("Configuration activated")
-
-		// Step 4: Restore ADC if available for this configuration
-		if cfg.ADCPath != "" {
-			logger.Info("Restoring saved ADC credentials", "name", configName)
-			if err := gcloud.RestoreADC(cfg.ADCPath); err != nil {
-				logger.Warning("Failed to restore ADC", "error", err)
-			} else {
-				logger.Success("ADC credentials restored")
+
+		// Configurations with the same account share their credentials; the shared ADC
+		// replaces a per-configuration one as it is the most recently refreshed
+		credentialsChanged := false
+		if account := cmp.Or(cfg.Account, previousAccount); account != "" {
+			key := cfg.CredentialKey(account)
+			previousKey := state.CredentialKey(cfg.Name)
+			state.BindIdentity(cfg.Name, key)
+			if sharedPath, err := config.GetADCFileForCredential(key); err == nil {
+				if _, err := os.Stat(sharedPath); err == nil {
+					cfg.ADCPath = sharedPath
+				} else if config.IsSharedADC(cfg.ADCPath) && cfg.ADCPath != sharedPath {
+					// Saved for other credentials, e.g. before the service account was edited
+					cfg.ADCPath = ""
+				}
 			}
+			// Without a saved ADC for the new credentials, the live ADC belongs to others
+			credentialsChanged = cfg.ADCPath == "" && previousKey != cfg.Name && previousKey != key
 		}
 
+		// Step 4: Restore ADC if available for this configuration. Undoing it also
+		// reverts the ADC written by a login in step 5.
+		steps = append(steps, step{
+			Name: "replace ADC",
+			Do: func(ctx context.Context) error {
+				if cfg.ADCPath == "" {
+					return nil
+				}
+				logger.Info("Restoring saved ADC credentials", "name", configName)
+				if err := gcloud.RestoreADC(cfg.ADCPath); err != nil {
+					logger.Warning("Failed to restore ADC", "error", err)
+				} else {
+					logger.Success("ADC credentials restored")
+				}
+				return nil
+			},
+			Undo: func(ctx context.Context) error { return previousADC.Restore() },
+		})
+
 		// Step 5: Check if we need to authenticate (check both account and ADC)
-		logger.Info("Checking authentication status...")
-		accountValid := gcloud.CheckAccountValid()
-		adcValid := gcloud.CheckADCValid()
-		needsAuth := !accountValid || !adcValid
-
-		if needsAuth {
-			if !accountValid {
-				logger.Info("Account credentials are invalid or expired")
-			}
-			if !adcValid {
-				logger.Info("ADC credentials are invalid or expired")
-			}
-			logger.Info("Authentication required...")
+		steps = append(steps, step{
+			Name: "authenticate",
+			Do: func(ctx context.Context) error {
+				if credentialsChanged {
+					logger.Info("The configuration uses other credentials than at its last login")
+					logger.Info("Authentication required...")
+					return loginConfiguration(ctx, state, cfg)
+				}
+				return ensureAuthenticated(ctx, state, cfg)
+			},
+		})
 
-			if cfg.ServiceAccount != "" {
-				logger.Info("Authenticating with service account", "service_account", cfg.ServiceAccount)
-				if err := gcloud.AuthLoginWithServiceAccount(cfg.ServiceAccount); err != nil {
+		// Step 6: Set the project for this configuration (after auth is confirmed)
+		steps = append(steps, step{
+			Name: "set project " + cfg.ProjectID,
+			Do: func(ctx context.Context) error {
+				if err := gcloud.SetProject(ctx, cfg.ProjectID); err != nil {
 					return err
 				}
-			} else {
-				logger.Info("Authenticating with user credentials...")
-				if err := gcloud.AuthLogin(); err != nil {
-					return err
+				logger.Success("Project set successfully")
+				return nil
+			},
+			Undo: func(ctx context.Context) error {
+				if previousProject == "" || previousProject == cfg.ProjectID {
+					return nil
 				}
-			}
-			logger.Success("Authentication successful")
+				return gcloud.SetProjectForConfiguration(ctx, configName, previousProject)
+			},
+		})
 
-			// Save the new ADC credentials
-			adcPath, err := config.GetADCFileForConfig(configName)
-			if err == nil {
-				if err := gcloud.SaveADC(adcPath); err != nil {
-					logger.Warning("Failed to save new ADC", "error", err)
-				} else {
-					cfg.ADCPath = adcPath
+		// Update active config
+		previousActive := store.ActiveConfig
+		steps = append(steps, step{
+			Name: "save active configuration",
+			Do: func(ctx context.Context) error {
+				store.ActiveConfig = cfg.Name
+				if err := store.Save(); err != nil {
+					store.ActiveConfig = previousActive
+					return fmt.Errorf("failed to save active configuration: %w", err)
 				}
+				return nil
+			},
+		})
+
+		if err := runSteps(ctx, steps); err != nil {
+			if errors.Is(err, errRollbackIncomplete) {
+				return fmt.Errorf("failed to switch to '%s', previous configuration only partially restored: %w", cfg.Name, err)
+			}
+			return fmt.Errorf("failed to switch to '%s', previous configuration restored: %w", cfg.Name, err)
+		}
+
+		state.RecordUse(cfg.Name, time.Now())
+		if err := state.Save(); err != nil {
+			logger.Debug("Failed to save state", "error", err)
+		}
+
+		logger.Success("Successfully switched to configuration", "name", cfg.Name)
+		return nil
+	},
+}
+
+// ensureAuthenticated logs in when the account or ADC credentials of the active
+// configuration are invalid, saving the new ADC for cfg
+func ensureAuthenticated(ctx context.Context, state *config.State, cfg *config.GCloudConfig) error {
+	logger.Info("Checking authentication status...")
+	if lacksADCSettings(state.Credentials[state.CredentialKey(cfg.Name)], cfg) {
+		logger.Info("The saved ADC was obtained with other scopes or another OAuth client")
+		logger.Info("Authentication required...")
+		return loginConfiguration(ctx, state, cfg)
+	}
+	// The live ADC of another configuration was not obtained with custom scopes or OAuth client
+	if cfg.ADCPath == "" && (len(cfg.ADCScopes) > 0 || cfg.ClientIDFile != "") {
+		logger.Info("No ADC was saved with the scopes and OAuth client of this configuration")
+		logger.Info("Authentication required...")
+		return loginConfiguration(ctx, state, cfg)
+	}
+	accountValid, adcValid, cached := validateCredentials(ctx, state, state.CredentialKey(cfg.Name), switchRevalidate)
+	if cached {
+		logger.Debug("Using cached credential check", "valid_until", state.Credentials[state.CredentialKey(cfg.Name)].ExpiresAt.Local().Format(time.Kitchen))
+	}
+	if accountValid && adcValid {
+		logger.Success("Using existing valid credentials")
+		return nil
+	}
+
+	if !accountValid {
+		logger.Info("Account credentials are invalid or expired")
+	}
+	if !adcValid {
+		logger.Info("ADC credentials are invalid or expired")
+	}
+	logger.Info("Authentication required...")
+	return loginConfiguration(ctx, state, cfg)
+}
+
+// loginConfiguration runs the login flow of cfg in the active gcloud configuration,
+// checks the account chosen and saves the new ADC where every configuration using it finds it
+func loginConfiguration(ctx context.Context, state *config.State, cfg *config.GCloudConfig) error {
+	opts := loginOptions(cfg)
+	var actual string
+	for attempt := 1; ; attempt++ {
+		if cfg.ServiceAccount != "" {
+			logger.Info("Authenticating with service account", "service_account", formatChain(cfg.ServiceAccount, cfg.Delegates))
+			if err := gcloud.AuthLoginWithServiceAccount(ctx, cfg.ImpersonationChain(), opts); err != nil {
+				return err
 			}
 		} else {
-			logger.Success("Using existing valid credentials")
+			logger.Info("Authenticating with user credentials...")
+			if err := gcloud.AuthLogin(ctx, opts); err != nil {
+				return err
+			}
 		}
 
-		// Step 6: Set the project for this configuration (after auth is confirmed)
-		if err := gcloud.SetProject(cfg.ProjectID); err != nil {
+		// gcloud cannot force the account picked in the browser, so check it
+		var err error
+		actual, err = gcloud.GetActiveAccount(ctx)
+		if err != nil && cfg.Account != "" {
 			return err
 		}
-		logger.Success("Project set successfully")
+		if cfg.Account == "" || actual == cfg.Account {
+			break
+		}
+		if attempt == maxLoginAttempts {
+			return fmt.Errorf("logged in as '%s' instead of '%s'", actual, cfg.Account)
+		}
+		logger.Warning("Logged in with a different account", "expected", cfg.Account, "actual", actual)
+		logger.Info("Please log in again and choose " + cfg.Account)
+	}
+	logger.Success("Authentication successful")
 
-		// Update active config
-		store.ActiveConfig = cfg.Name
-		if err := store.Save(); err != nil {
-			return fmt.Errorf("failed to save active configuration: %w", err)
+	// Save the new ADC credentials where every configuration using the account finds them
+	adcPath, err := config.GetADCFileForConfig(cfg.Name)
+	if actual != "" {
+		key := cfg.CredentialKey(actual)
+		state.BindIdentity(cfg.Name, key)
+		adcPath, err = config.GetADCFileForCredential(key)
+	}
+	// Check again to cache the expiry of the new tokens
+	validateCredentials(ctx, state, state.CredentialKey(cfg.Name), true)
+	state.RecordLogin(state.CredentialKey(cfg.Name), time.Now(), cfg.ADCScopes, cfg.ClientIDFile)
+
+	if err == nil {
+		if err := gcloud.SaveADC(adcPath); err != nil {
+			logger.Warning("Failed to save new ADC", "error", err)
+		} else {
+			cfg.ADCPath = adcPath
 		}
+	}
+	return nil
+}
 
-		logger.Success("Successfully switched to configuration", "name", cfg.Name)
-		return nil
-	},
+// maxLoginAttempts bounds the logins retried when the wrong account was chosen
+const maxLoginAttempts = 3
+
+func init() {
+	switchCmd.Flags().BoolVar(&switchRevalidate, "revalidate", false, "Check the credentials with gcloud even if a cached check is still valid")
 }
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// Sources accepted by sync --from
+const (
+	syncFromStore  = "store"
+	syncFromGCloud = "gcloud"
+)
+
+var syncFrom string
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Reconcile gcloud-switcher with changes made directly in gcloud",
+	Long: `Compare the active configuration of gcloud-switcher with gcloud: the active
+native configuration, its project, account and impersonated service account, and
+the identity of the live ADC. Every difference is then reconciled.
+
+With --from store (the default) gcloud is changed to match gcloud-switcher, for
+example after 'gcloud config set project'. With --from gcloud the gcloud-switcher
+configuration is updated instead, keeping the changes made in gcloud.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if syncFrom != syncFromStore && syncFrom != syncFromGCloud {
+			return fmt.Errorf("invalid value '%s' for --from: expected %s or %s", syncFrom, syncFromStore, syncFromGCloud)
+		}
+
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+		if store.ActiveConfig == "" {
+			logger.Info("No active configuration tracked by gcloud-switcher, nothing to sync.")
+			return nil
+		}
+
+		items := detectDrift(ctx, store)
+		if len(items) == 0 {
+			logger.Success("gcloud-switcher and gcloud are in sync")
+			return nil
+		}
+
+		changed := 0
+		// Adopting another active configuration from gcloud changes what its properties
+		// are compared with, so they are checked once more
+		for pass := 0; pass < 2 && len(items) > 0; pass++ {
+			activeAdopted := false
+			for _, item := range items {
+				logger.Info("Drift detected", "field", item.Field, "store", item.Store, "gcloud", item.GCloud)
+				if syncFrom == syncFromStore {
+					if item.ToGCloud == nil {
+						logger.Warning("  Cannot be changed in gcloud automatically", "field", item.Field)
+						continue
+					}
+					if err := item.ToGCloud(ctx); err != nil {
+						return fmt.Errorf("failed to sync %s: %w", item.Field, err)
+					}
+				} else {
+					if item.ToStore == nil {
+						logger.Warning("  Cannot be adopted by gcloud-switcher automatically", "field", item.Field)
+						continue
+					}
+					if err := item.ToStore(); err != nil {
+						return fmt.Errorf("failed to sync %s: %w", item.Field, err)
+					}
+					activeAdopted = activeAdopted || item.Field == driftActive
+				}
+				changed++
+			}
+			if !activeAdopted {
+				break
+			}
+			items = detectDrift(ctx, store)
+		}
+
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+		logger.Success("Synchronized gcloud-switcher and gcloud", "from", syncFrom, "changes", changed)
+		return nil
+	},
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncFrom, "from", syncFromStore, "Side whose values win: store (change gcloud) or gcloud (change gcloud-switcher)")
+	_ = syncCmd.RegisterFlagCompletionFunc("from", cobra.FixedCompletions([]string{syncFromStore, syncFromGCloud}, cobra.ShellCompDirectiveNoFileComp)) //nolint:errcheck
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// addTagSelectorFlag registers a --tag flag selecting configurations by tag
+func addTagSelectorFlag(cmd *cobra.Command, tags *[]string, usage string) {
+	cmd.Flags().StringSliceVar(tags, "tag", nil, usage)
+	_ = cmd.RegisterFlagCompletionFunc("tag", GetTagNames) //nolint:errcheck
+}
+
+// selectByTags returns the configurations matching a --tag selector, failing when none match
+func selectByTags(store *config.ConfigStore, values []string) ([]*config.GCloudConfig, error) {
+	tags, err := config.ParseTags(values)
+	if err != nil {
+		return nil, err
+	}
+	selected := store.SelectByTags(tags)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no configuration is tagged %s", strings.Join(tags, ", "))
+	}
+	return selected, nil
+}
+
+// configNames lists the names of configurations, e.g. for confirmation prompts
+func configNames(configs []*config.GCloudConfig) string {
+	names := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		names = append(names, cfg.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// GetTagNames returns every tag in use for autocompletion of --tag
+func GetTagNames(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	store, err := config.LoadConfigStore()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return store.AllTags(), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"gcloud-switch/internal/output"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenIdentity bool
+	tokenAudience string
+	tokenScopes   []string
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token <name>",
+	Short: "Print an access or identity token for a configuration",
+	Long: `Print a token for any configuration without switching to it, e.g. for
+curl -H "Authorization: Bearer $(gcloud-switcher token prod)".
+
+Access tokens come from the saved ADC of the configuration, so a configuration
+impersonating a service account gets a token for that service account. Identity
+tokens are issued by gcloud for the account of the configuration, or for the
+service account it impersonates; only the latter can have an audience.
+
+With -o json the token is printed along with its expiry.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: GetConfigNames,
+	Annotations:       map[string]string{stdoutDataAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+		if tokenIdentity && len(tokenScopes) > 0 {
+			return errors.New("--scopes only applies to access tokens")
+		}
+		if tokenAudience != "" && !tokenIdentity {
+			return errors.New("--audience requires --id-token")
+		}
+
+		store, err := config.LoadConfigStore()
+		if err != nil {
+			return fmt.Errorf("failed to load configurations: %w", err)
+		}
+		cfg, err := resolveConfig(store, args[0])
+		if err != nil {
+			return err
+		}
+
+		view := tokenView{Name: cfg.Name}
+		if tokenIdentity {
+			if tokenAudience != "" && cfg.ServiceAccount == "" {
+				return fmt.Errorf("configuration '%s' does not impersonate a service account, gcloud cannot set the audience of user identity tokens", cfg.Name)
+			}
+			view.Type = tokenTypeIdentity
+			view.Token, err = gcloud.PrintIdentityToken(ctx, cfg.Name, cfg.ImpersonationChain(), tokenAudience)
+			if err != nil {
+				return err
+			}
+			if expiry, err := gcloud.IdentityTokenExpiry(view.Token); err == nil {
+				view.ExpiresAt = &expiry
+			} else {
+				logger.Debug("Identity token expiry unavailable", "error", err)
+			}
+		} else {
+			account := cfg.Account
+			if account == "" {
+				account, _ = gcloud.GetAccountFromConfiguration(ctx, cfg.Name)
+			}
+			adcPath := configADCPath(cfg, account, cfg.Name == store.ActiveConfig)
+			if _, err := os.Stat(adcPath); adcPath == "" || err != nil {
+				return fmt.Errorf("configuration '%s' has no saved ADC, switch to it once to log in", cfg.Name)
+			}
+			token, err := gcloud.PrintADCFileAccessToken(ctx, adcPath, tokenScopes...)
+			if err != nil {
+				return err
+			}
+			view.Type = tokenTypeAccess
+			view.Token = token.Token
+			view.ExpiresAt = &token.Expiry
+		}
+
+		if !format.IsText() {
+			return output.Write(cmd.OutOrStdout(), format, view)
+		}
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), view.Token)
+		return err
+	},
+}
+
+func init() {
+	tokenCmd.Flags().BoolVar(&tokenIdentity, "id-token", false, "Print an identity token instead of an access token")
+	tokenCmd.Flags().StringVar(&tokenAudience, "audience", "", "Audience of the identity token, e.g. the URL of a Cloud Run service")
+	tokenCmd.Flags().StringSliceVar(&tokenScopes, "scopes", nil, "OAuth scopes of the access token, e.g. --scopes https://www.googleapis.com/auth/drive")
+}
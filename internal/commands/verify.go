@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/logger"
+	"strings"
+)
+
+// validateConfigValues checks the syntax of a project ID and an optional service account
+// with the delegates impersonated before it
+func validateConfigValues(projectID, serviceAccount string, delegates []string) error {
+	return errors.Join(config.ValidateProjectID(projectID), config.ValidateServiceAccount(serviceAccount),
+		config.ValidateDelegates(delegates, serviceAccount))
+}
+
+// parseListFlag drops the empty values of a repeatable flag, so e.g. --delegate "" clears the list
+func parseListFlag(values []string) []string {
+	var list []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// verifyConfigValues confirms with gcloud that the active account can access the project
+// and impersonate the service account through its delegates, reporting every failed check
+func verifyConfigValues(ctx context.Context, projectID, serviceAccount string, delegates []string) error {
+	logger.Info("Verifying access with gcloud...")
+	var errs []error
+
+	if err := gcloud.VerifyProject(ctx, projectID); err != nil {
+		errs = append(errs, err)
+	} else {
+		logger.Success("Project is accessible", "project_id", projectID)
+	}
+
+	if serviceAccount != "" {
+		if err := gcloud.VerifyServiceAccount(ctx, serviceAccount); err != nil {
+			errs = append(errs, err)
+		} else {
+			logger.Success("Service account exists", "service_account", serviceAccount)
+			chain := config.GCloudConfig{ServiceAccount: serviceAccount, Delegates: delegates}.ImpersonationChain()
+			if err := gcloud.VerifyImpersonation(ctx, chain); err != nil {
+				errs = append(errs, err)
+			} else {
+				logger.Success("Service account can be impersonated", "chain", formatChain(serviceAccount, delegates))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -22,10 +22,11 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
 	Long:  "Display version, commit, and build information for gcloud-switcher.",
-	Run: func(_ *cobra.Command, _ []string) {
-		fmt.Printf("gcloud-switcher version %s\n", Version)
-		fmt.Printf("  commit: %s\n", Commit)
-		fmt.Printf("  built at: %s\n", Date)
-		fmt.Printf("  built by: %s\n", BuiltBy)
+	Run: func(cmd *cobra.Command, _ []string) {
+		out := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(out, "gcloud-switcher version %s\n", Version) //nolint:errcheck
+		_, _ = fmt.Fprintf(out, "  commit: %s\n", Commit)                //nolint:errcheck
+		_, _ = fmt.Fprintf(out, "  built at: %s\n", Date)                //nolint:errcheck
+		_, _ = fmt.Fprintf(out, "  built by: %s\n", BuiltBy)             //nolint:errcheck
 	},
 }
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"fmt"
+	"gcloud-switch/internal/config"
+	"gcloud-switch/internal/gcloud"
+	"gcloud-switch/internal/output"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The view types below define the machine-readable output of the commands.
+// Their field names are a stable contract: add fields, never rename or remove them.
+
+// configView is the machine-readable representation of a configuration
+type configView struct {
+	Name           string   `json:"name" yaml:"name"`
+	ProjectID      string   `json:"project_id" yaml:"project_id"`
+	ServiceAccount string   `json:"service_account" yaml:"service_account"`
+	Delegates      []string `json:"delegates" yaml:"delegates"`
+	Account        string   `json:"account" yaml:"account"`
+	Active         bool     `json:"active" yaml:"active"`
+	Protected      bool     `json:"protected" yaml:"protected"`
+	Tags           []string `json:"tags" yaml:"tags"`
+	Aliases        []string `json:"aliases" yaml:"aliases"`
+	ADCScopes      []string `json:"adc_scopes" yaml:"adc_scopes"`
+	ClientIDFile   string   `json:"client_id_file" yaml:"client_id_file"`
+}
+
+func newConfigView(cfg config.GCloudConfig, activeConfig string) configView {
+	return configView{
+		Name:           cfg.Name,
+		ProjectID:      cfg.ProjectID,
+		ServiceAccount: cfg.ServiceAccount,
+		Delegates:      append([]string{}, cfg.Delegates...),
+		Account:        cfg.Account,
+		Active:         cfg.Name == activeConfig,
+		Protected:      cfg.Protected,
+		Tags:           append([]string{}, cfg.Tags...),
+		Aliases:        append([]string{}, cfg.Aliases...),
+		ADCScopes:      append([]string{}, cfg.ADCScopes...),
+		ClientIDFile:   cfg.ClientIDFile,
+	}
+}
+
+// configListView is the output of list
+type configListView []configView
+
+func (v configListView) Header() []string {
+	return []string{"ACTIVE", "NAME", "PROJECT", "ACCOUNT", "SERVICE ACCOUNT", "TAGS"}
+}
+
+func (v configListView) Rows() [][]string {
+	rows := make([][]string, 0, len(v))
+	for _, cfg := range v {
+		active := ""
+		if cfg.Active {
+			active = "*"
+		}
+		rows = append(rows, []string{active, cfg.Name, cfg.ProjectID, cfg.Account, formatChain(cfg.ServiceAccount, cfg.Delegates), strings.Join(cfg.Tags, ",")})
+	}
+	return rows
+}
+
+// currentView is the output of current
+type currentView struct {
+	ActiveConfig        string      `json:"active_config" yaml:"active_config"`
+	GCloudConfiguration string      `json:"gcloud_configuration" yaml:"gcloud_configuration"`
+	GCloudProject       string      `json:"gcloud_project" yaml:"gcloud_project"`
+	ADCValid            bool        `json:"adc_valid" yaml:"adc_valid"`
+	Configuration       *configView `json:"configuration" yaml:"configuration"`
+	Drift               []driftView `json:"drift" yaml:"drift"`
+}
+
+// driftView is a value on which gcloud-switcher and gcloud disagree
+type driftView struct {
+	Field  string `json:"field" yaml:"field"`
+	Store  string `json:"store" yaml:"store"`
+	GCloud string `json:"gcloud" yaml:"gcloud"`
+}
+
+func (v currentView) Header() []string {
+	return []string{"NAME", "PROJECT", "ACCOUNT", "SERVICE ACCOUNT", "GCLOUD CONFIGURATION", "GCLOUD PROJECT", "ADC VALID"}
+}
+
+func (v currentView) Rows() [][]string {
+	row := []string{"", "", "", "", v.GCloudConfiguration, v.GCloudProject, formatBool(v.ADCValid)}
+	if v.Configuration != nil {
+		row[0], row[1], row[2], row[3] = v.Configuration.Name, v.Configuration.ProjectID, v.Configuration.Account, formatChain(v.Configuration.ServiceAccount, v.Configuration.Delegates)
+	}
+	return [][]string{row}
+}
+
+// formatChain shows a service account with the delegates impersonated before it, in order
+func formatChain(serviceAccount string, delegates []string) string {
+	if serviceAccount == "" || len(delegates) == 0 {
+		return serviceAccount
+	}
+	return strings.Join(append(slices.Clone(delegates), serviceAccount), " → ")
+}
+
+// Credential types shown by status
+const (
+	credentialTypeNone          = "none"
+	credentialTypeUser          = "user"
+	credentialTypeImpersonated  = "impersonated"
+	credentialTypeServiceAccKey = "service account key"
+)
+
+// credentialType names the type of an ADC file
+func credentialType(adcType string) string {
+	switch adcType {
+	case gcloud.ADCAuthorizedUser:
+		return credentialTypeUser
+	case gcloud.ADCImpersonated:
+		return credentialTypeImpersonated
+	case gcloud.ADCServiceAccountKey:
+		return credentialTypeServiceAccKey
+	default:
+		return adcType
+	}
+}
+
+// statusView is the credential health of a configuration in the output of status
+type statusView struct {
+	Name           string     `json:"name" yaml:"name"`
+	Active         bool       `json:"active" yaml:"active"`
+	Account        string     `json:"account" yaml:"account"`
+	AccountValid   bool       `json:"account_valid" yaml:"account_valid"`
+	ADCValid       bool       `json:"adc_valid" yaml:"adc_valid"`
+	CredentialType string     `json:"credential_type" yaml:"credential_type"`
+	NeedsLogin     bool       `json:"needs_login" yaml:"needs_login"`
+	ExpiresAt      *time.Time `json:"expires_at" yaml:"expires_at"`
+	LastUsed       *time.Time `json:"last_used" yaml:"last_used"`
+	LastLogin      *time.Time `json:"last_login" yaml:"last_login"`
+}
+
+// statusListView is the output of status
+type statusListView []statusView
+
+func (v statusListView) Header() []string {
+	return []string{"ACTIVE", "NAME", "ACCOUNT", "ACCOUNT VALID", "ADC VALID", "TYPE", "LAST USED", "LAST LOGIN"}
+}
+
+func (v statusListView) Rows() [][]string {
+	now := time.Now()
+	rows := make([][]string, 0, len(v))
+	for _, status := range v {
+		active := ""
+		if status.Active {
+			active = "*"
+		}
+		rows = append(rows, []string{
+			active, status.Name, status.Account, formatBool(status.AccountValid), formatBool(status.ADCValid),
+			status.CredentialType, formatAge(status.LastUsed, now), formatAge(status.LastLogin, now),
+		})
+	}
+	return rows
+}
+
+// formatAge shows how long ago a time was, e.g. 3h ago
+func formatAge(at *time.Time, now time.Time) string {
+	if at == nil {
+		return "never"
+	}
+	age := now.Sub(*at)
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
+
+// adcView is the output of adc inspect
+type adcView struct {
+	Path           string   `json:"path" yaml:"path"`
+	Configuration  string   `json:"configuration" yaml:"configuration"`
+	Type           string   `json:"type" yaml:"type"`
+	Account        string   `json:"account" yaml:"account"`
+	ServiceAccount string   `json:"service_account" yaml:"service_account"`
+	Delegates      []string `json:"delegates" yaml:"delegates"`
+	Problems       []string `json:"problems" yaml:"problems"`
+}
+
+// Token types printed by token
+const (
+	tokenTypeAccess   = "access_token"
+	tokenTypeIdentity = "id_token"
+)
+
+// tokenView is the output of token
+type tokenView struct {
+	Name      string     `json:"name" yaml:"name"`
+	Type      string     `json:"type" yaml:"type"`
+	Token     string     `json:"token" yaml:"token"`
+	ExpiresAt *time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// outputFormat parses the global --output flag
+func outputFormat() (output.Format, error) {
+	return output.Parse(outputFlag)
+}
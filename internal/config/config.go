@@ -1,24 +1,42 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"gcloud-switch/internal/dryrun"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // GCloudConfig represents a single GCloud configuration
 type GCloudConfig struct {
-	Name           string `json:"name"`
-	ProjectID      string `json:"project_id"`
-	ServiceAccount string `json:"service_account,omitempty"`
-	ADCPath        string `json:"adc_path,omitempty"` // Path to stored ADC file
+	Name           string   `json:"name"`
+	ProjectID      string   `json:"project_id"`
+	ServiceAccount string   `json:"service_account,omitempty"`
+	Delegates      []string `json:"delegates,omitempty"` // Service accounts impersonated in turn to reach ServiceAccount
+	Account        string   `json:"account,omitempty"`   // User account logged in for this configuration
+	ADCPath        string   `json:"adc_path,omitempty"`  // Path to stored ADC file
+	Protected      bool     `json:"protected,omitempty"` // Marks sensitive (e.g. production) configurations
+	Tags           []string `json:"tags,omitempty"`      // Labels such as env:prod or client:acme
+	Aliases        []string `json:"aliases,omitempty"`   // Short names accepted wherever a name is
+	// BrowserMode is how logins obtain consent: browser, no-browser or no-launch-browser; empty detects it
+	BrowserMode string `json:"browser_mode,omitempty"`
+	// ADCScopes are OAuth scopes requested for ADC in addition to gcloud's defaults, e.g. for Drive
+	ADCScopes []string `json:"adc_scopes,omitempty"`
+	// ClientIDFile is an OAuth client secrets file used for ADC logins instead of gcloud's client
+	ClientIDFile string `json:"client_id_file,omitempty"`
 }
 
 // ConfigStore manages all configurations
 type ConfigStore struct {
 	Configurations []GCloudConfig `json:"configurations"`
 	ActiveConfig   string         `json:"active_config,omitempty"`
+	// OwnedConfigurations lists the native gcloud configurations created by gcloud-switcher
+	OwnedConfigurations []string `json:"owned_configurations,omitempty"`
 }
 
 // GetConfigPath returns the path to the configuration file
@@ -56,6 +74,82 @@ func GetADCFileForConfig(configName string) (string, error) {
 	return filepath.Join(adcDir, configName+".json"), nil
 }
 
+// GetSharedADCStoragePath returns the directory where ADC files shared by the
+// configurations using the same account are stored
+func GetSharedADCStoragePath() (string, error) {
+	adcDir, err := GetADCStoragePath()
+	if err != nil {
+		return "", err
+	}
+	sharedDir := filepath.Join(adcDir, "accounts")
+	if err := os.MkdirAll(sharedDir, 0700); err != nil {
+		return "", err
+	}
+	return sharedDir, nil
+}
+
+// CredentialKey identifies the credentials of a user account and, for ADC, the
+// impersonation chain it goes through
+func CredentialKey(account, chain string) string {
+	if chain == "" {
+		return account
+	}
+	return account + "_as_" + chain
+}
+
+// CredentialKey identifies the credentials cfg uses when logged in as account: the
+// impersonation chain and, when customized, the ADC scopes and OAuth client, so that
+// configurations only share an ADC obtained exactly the way each of them asks for
+func (c GCloudConfig) CredentialKey(account string) string {
+	key := CredentialKey(account, c.ImpersonationChain())
+	if len(c.ADCScopes) == 0 && c.ClientIDFile == "" {
+		return key
+	}
+	scopes := slices.Compact(slices.Sorted(slices.Values(c.ADCScopes)))
+	sum := sha256.Sum256([]byte(strings.Join(scopes, ",") + "\n" + c.ClientIDFile))
+	return key + "_adc_" + hex.EncodeToString(sum[:6])
+}
+
+// ImpersonationChain returns the delegates and the service account of a configuration
+// as the comma-separated list taken by --impersonate-service-account; empty when none
+func (c GCloudConfig) ImpersonationChain() string {
+	if c.ServiceAccount == "" {
+		return ""
+	}
+	return strings.Join(append(slices.Clone(c.Delegates), c.ServiceAccount), ",")
+}
+
+// SplitImpersonationChain splits a comma-separated impersonation chain into the
+// service account impersonated last and the delegates before it
+func SplitImpersonationChain(chain string) (serviceAccount string, delegates []string) {
+	var accounts []string
+	for account := range strings.SplitSeq(chain, ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) == 0 {
+		return "", nil
+	}
+	return accounts[len(accounts)-1], accounts[:len(accounts)-1]
+}
+
+// GetADCFileForCredential returns the path where the ADC of a credential key is stored,
+// shared by every configuration with that key
+func GetADCFileForCredential(key string) (string, error) {
+	sharedDir, err := GetSharedADCStoragePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(sharedDir, strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key)+".json"), nil
+}
+
+// IsSharedADC reports whether an ADC path is stored per account rather than per configuration
+func IsSharedADC(path string) bool {
+	sharedDir, err := GetSharedADCStoragePath()
+	return err == nil && path != "" && filepath.Dir(path) == sharedDir
+}
+
 // LoadConfigStore loads the configuration store from disk
 func LoadConfigStore() (*ConfigStore, error) {
 	configPath, err := GetConfigPath()
@@ -95,7 +189,12 @@ func (cs *ConfigStore) Save() error {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644) //nolint:gosec
+	if dryrun.Enabled() {
+		dryrun.Record("write %s", configPath)
+		return nil
+	}
+
+	return os.WriteFile(configPath, data, 0600)
 }
 
 // FindConfig finds a configuration by name
@@ -144,3 +243,71 @@ func (cs *ConfigStore) UpdateConfig(name string, projectID, serviceAccount strin
 	config.ServiceAccount = serviceAccount
 	return nil
 }
+
+// RenameConfig renames a configuration, keeping the active configuration and ownership marker in sync
+func (cs *ConfigStore) RenameConfig(oldName, newName string) error {
+	if _, err := cs.FindConfig(newName); err == nil {
+		return errors.New("configuration with this name already exists")
+	}
+	config, err := cs.FindConfig(oldName)
+	if err != nil {
+		return err
+	}
+	config.Name = newName
+	if cs.ActiveConfig == oldName {
+		cs.ActiveConfig = newName
+	}
+	if cs.IsOwned(oldName) {
+		cs.Disown(oldName)
+		cs.MarkOwned(newName)
+	}
+	return nil
+}
+
+// MarkOwned records that a native gcloud configuration was created by gcloud-switcher
+func (cs *ConfigStore) MarkOwned(name string) {
+	if cs.IsOwned(name) {
+		return
+	}
+	cs.OwnedConfigurations = append(cs.OwnedConfigurations, name)
+}
+
+// IsOwned reports whether a native gcloud configuration was created by gcloud-switcher
+func (cs *ConfigStore) IsOwned(name string) bool {
+	for _, owned := range cs.OwnedConfigurations {
+		if owned == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Disown forgets the ownership marker of a native gcloud configuration
+func (cs *ConfigStore) Disown(name string) {
+	for i, owned := range cs.OwnedConfigurations {
+		if owned == name {
+			cs.OwnedConfigurations = append(cs.OwnedConfigurations[:i], cs.OwnedConfigurations[i+1:]...)
+			return
+		}
+	}
+}
+
+// ConfigsUsingADC lists the configurations other than except whose saved ADC is path
+func (cs *ConfigStore) ConfigsUsingADC(path, except string) []string {
+	var names []string
+	for _, cfg := range cs.Configurations {
+		if cfg.Name != except && path != "" && cfg.ADCPath == path {
+			names = append(names, cfg.Name)
+		}
+	}
+	return names
+}
+
+// ForgetADC clears the saved ADC path of every configuration using path
+func (cs *ConfigStore) ForgetADC(path string) {
+	for i := range cs.Configurations {
+		if cs.Configurations[i].ADCPath == path {
+			cs.Configurations[i].ADCPath = ""
+		}
+	}
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"gcloud-switch/internal/dryrun"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGCloudConfig(t *testing.T) {
@@ -163,3 +166,383 @@ func TestConfigStoreUpdateConfig(t *testing.T) {
 		t.Error("Expected error when updating non-existing config, got nil")
 	}
 }
+func TestConfigStoreOwnership(t *testing.T) {
+	store := &ConfigStore{}
+	store.MarkOwned("dev")
+	store.MarkOwned("dev")
+	if len(store.OwnedConfigurations) != 1 {
+		t.Errorf("Expected ownership to be recorded once, got %v", store.OwnedConfigurations)
+	}
+	if !store.IsOwned("dev") {
+		t.Error("Expected 'dev' to be owned")
+	}
+	store.Disown("dev")
+	if store.IsOwned("dev") {
+		t.Error("Expected 'dev' to no longer be owned")
+	}
+}
+func TestConfigStoreRenameConfig(t *testing.T) {
+	store := &ConfigStore{
+		Configurations: []GCloudConfig{
+			{Name: "dev", ProjectID: "dev-project"},
+			{Name: "prod", ProjectID: "prod-project"},
+		},
+		ActiveConfig:        "dev",
+		OwnedConfigurations: []string{"dev"},
+	}
+	if err := store.RenameConfig("dev", "prod"); err == nil {
+		t.Error("Expected error when renaming to an existing name, got nil")
+	}
+	if err := store.RenameConfig("dev", "development"); err != nil {
+		t.Fatalf("Expected to rename config successfully, got error: %v", err)
+	}
+	if _, err := store.FindConfig("development"); err != nil {
+		t.Errorf("Expected to find renamed config, got error: %v", err)
+	}
+	if store.ActiveConfig != "development" {
+		t.Errorf("Expected ActiveConfig to follow the rename, got '%s'", store.ActiveConfig)
+	}
+	if !store.IsOwned("development") || store.IsOwned("dev") {
+		t.Errorf("Expected ownership marker to follow the rename, got %v", store.OwnedConfigurations)
+	}
+	if err := store.RenameConfig("staging", "qa"); err == nil {
+		t.Error("Expected error when renaming non-existing config, got nil")
+	}
+}
+func TestConfigStoreSaveDryRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dryrun.Enable()
+	defer dryrun.Reset()
+
+	store := &ConfigStore{Configurations: []GCloudConfig{{Name: "dev", ProjectID: "dev-project"}}}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	configPath, _ := GetConfigPath()
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Error("Expected the configuration file not to be written in dry-run mode")
+	}
+	if len(dryrun.Operations()) != 1 {
+		t.Errorf("Expected the save to be recorded, got: %v", dryrun.Operations())
+	}
+}
+func TestStateSaveAndLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	state := LoadState()
+	if len(state.Credentials) != 0 {
+		t.Errorf("Expected empty state when no file exists, got %v", state.Credentials)
+	}
+
+	checkedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	state.RecordCredentials("dev", true, checkedAt, checkedAt.Add(time.Hour))
+	state.RecordCredentials("prod", false, checkedAt, time.Time{})
+	state.RenameCredentials("prod", "production")
+	state.ForgetCredentials("missing")
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	loaded := LoadState()
+	if dev := loaded.Credentials["dev"]; !dev.Valid || !dev.CheckedAt.Equal(checkedAt) {
+		t.Errorf("Unexpected credentials for 'dev': %+v", dev)
+	}
+	if _, ok := loaded.Credentials["prod"]; ok {
+		t.Error("Expected 'prod' credentials to be renamed")
+	}
+	if production, ok := loaded.Credentials["production"]; !ok || production.Valid {
+		t.Errorf("Unexpected credentials for 'production': %+v", production)
+	}
+}
+func TestStateSharesCredentialsByIdentity(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	checkedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	state := LoadState()
+	state.RecordCredentials("dev", false, checkedAt, time.Time{})
+	key := CredentialKey("jane@example.com", "")
+	state.BindIdentity("dev", key)
+	state.BindIdentity("staging", key)
+	state.RecordCredentials(state.CredentialKey("dev"), true, checkedAt, checkedAt.Add(time.Hour))
+
+	if _, ok := state.Credentials["dev"]; ok {
+		t.Error("Expected the check cached under the name to be superseded")
+	}
+	if !state.Credentials[state.CredentialKey("staging")].Valid {
+		t.Error("Expected 'staging' to share the check of 'dev'")
+	}
+	if state.CredentialKey("prod") != "prod" {
+		t.Errorf("Expected an unbound configuration to use its name, got '%s'", state.CredentialKey("prod"))
+	}
+
+	state.RenameCredentials("staging", "stage")
+	state.ForgetCredentials("dev")
+	if state.CredentialKey("stage") != key || state.CredentialKey("dev") != "dev" {
+		t.Errorf("Unexpected identities: %v", state.Identities)
+	}
+	if _, ok := state.Credentials[key]; !ok {
+		t.Error("Expected the shared check to survive forgetting one configuration")
+	}
+}
+
+func TestSharedADCFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if CredentialKey("jane@example.com", "sa@p.iam.gserviceaccount.com") == CredentialKey("jane@example.com", "") {
+		t.Error("Expected the impersonated service account to be part of the key")
+	}
+	shared, err := GetADCFileForCredential(CredentialKey("jane@example.com", ""))
+	if err != nil {
+		t.Fatalf("Failed to get shared ADC path: %v", err)
+	}
+	perConfig, err := GetADCFileForConfig("dev")
+	if err != nil {
+		t.Fatalf("Failed to get ADC path: %v", err)
+	}
+	if !IsSharedADC(shared) || IsSharedADC(perConfig) || IsSharedADC("") {
+		t.Errorf("Unexpected shared detection for '%s' and '%s'", shared, perConfig)
+	}
+
+	store := &ConfigStore{Configurations: []GCloudConfig{
+		{Name: "dev", ADCPath: shared},
+		{Name: "staging", ADCPath: shared},
+		{Name: "prod", ADCPath: perConfig},
+	}}
+	if users := store.ConfigsUsingADC(shared, "dev"); len(users) != 1 || users[0] != "staging" {
+		t.Errorf("Expected 'staging' to use the shared ADC, got %v", users)
+	}
+	store.ForgetADC(shared)
+	if store.Configurations[0].ADCPath != "" || store.Configurations[1].ADCPath != "" || store.Configurations[2].ADCPath != perConfig {
+		t.Errorf("Expected only the shared ADC to be forgotten, got %+v", store.Configurations)
+	}
+}
+
+func TestCredentialStateFresh(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		state    CredentialState
+		expected bool
+	}{
+		{"valid and far from expiry", CredentialState{Valid: true, ExpiresAt: now.Add(30 * time.Minute)}, true},
+		{"valid but expiring within margin", CredentialState{Valid: true, ExpiresAt: now.Add(2 * time.Minute)}, false},
+		{"valid without known expiry", CredentialState{Valid: true}, false},
+		{"invalid", CredentialState{Valid: false, ExpiresAt: now.Add(30 * time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.state.Fresh(now, 5*time.Minute); got != tt.expected {
+			t.Errorf("%s: expected Fresh to be %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestValidateProjectID(t *testing.T) {
+	valid := []string{"my-project", "project-123456", "example.com:legacy-project"}
+	for _, id := range valid {
+		if err := ValidateProjectID(id); err != nil {
+			t.Errorf("Expected '%s' to be valid, got: %v", id, err)
+		}
+	}
+
+	invalid := []string{"", "short", "My-Project", "1project", "project-", "my_project", "a-project-id-that-is-far-too-long"}
+	for _, id := range invalid {
+		if err := ValidateProjectID(id); err == nil {
+			t.Errorf("Expected '%s' to be invalid", id)
+		}
+	}
+}
+
+func TestValidateServiceAccount(t *testing.T) {
+	valid := []string{"", "deployer@my-project.iam.gserviceaccount.com", "123456-compute@developer.gserviceaccount.com", "my-project@appspot.gserviceaccount.com"}
+	for _, email := range valid {
+		if err := ValidateServiceAccount(email); err != nil {
+			t.Errorf("Expected '%s' to be valid, got: %v", email, err)
+		}
+	}
+
+	invalid := []string{"deployer", "user@example.com", "deployer@my-project.iam.gserviceaccount.com.evil.com", "Deployer@my-project.iam.gserviceaccount.com"}
+	for _, email := range invalid {
+		if err := ValidateServiceAccount(email); err == nil {
+			t.Errorf("Expected '%s' to be invalid", email)
+		}
+	}
+}
+
+func TestValidateAccount(t *testing.T) {
+	for _, email := range []string{"", "jane@example.com", "jane.doe+ops@corp.example.co.uk"} {
+		if err := ValidateAccount(email); err != nil {
+			t.Errorf("Expected '%s' to be valid, got: %v", email, err)
+		}
+	}
+	for _, email := range []string{"jane", "jane@example", "jane doe@example.com", "jane@@example.com"} {
+		if err := ValidateAccount(email); err == nil {
+			t.Errorf("Expected '%s' to be invalid", email)
+		}
+	}
+}
+
+func TestImpersonationChain(t *testing.T) {
+	broker := "broker@shared-project.iam.gserviceaccount.com"
+	deployer := "deployer@prod-project.iam.gserviceaccount.com"
+
+	cfg := GCloudConfig{ServiceAccount: deployer, Delegates: []string{broker}}
+	chain := cfg.ImpersonationChain()
+	if chain != broker+","+deployer {
+		t.Errorf("Unexpected chain: %s", chain)
+	}
+	if serviceAccount, delegates := SplitImpersonationChain(chain); serviceAccount != deployer || len(delegates) != 1 || delegates[0] != broker {
+		t.Errorf("Unexpected split of '%s': %s %v", chain, serviceAccount, delegates)
+	}
+	if CredentialKey("jane@example.com", chain) == CredentialKey("jane@example.com", deployer) {
+		t.Error("Expected different chains to use different credentials")
+	}
+	if (GCloudConfig{}).ImpersonationChain() != "" {
+		t.Error("Expected no chain without a service account")
+	}
+
+	if err := ValidateDelegates([]string{broker}, deployer); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, delegates := range [][]string{{"broker"}, {broker, broker}, {deployer}} {
+		if err := ValidateDelegates(delegates, deployer); err == nil {
+			t.Errorf("Expected delegates %v to be invalid", delegates)
+		}
+	}
+	if err := ValidateDelegates([]string{broker}, ""); err == nil {
+		t.Error("Expected delegates without a service account to be invalid")
+	}
+}
+
+func TestCredentialsCoverScopes(t *testing.T) {
+	drive := "https://www.googleapis.com/auth/drive"
+	sheets := "https://www.googleapis.com/auth/spreadsheets"
+	state := newState()
+	state.RecordLogin("jane@example.com", time.Now(), []string{drive, sheets}, "")
+	state.RecordCredentials("jane@example.com", true, time.Now(), time.Now().Add(time.Hour))
+
+	credentials := state.Credentials["jane@example.com"]
+	if !credentials.Covers(nil, "") || !credentials.Covers([]string{drive}, "") {
+		t.Error("Expected a login with more scopes to be reusable")
+	}
+	if credentials.Covers([]string{"https://www.googleapis.com/auth/bigquery"}, "") {
+		t.Error("Expected a new scope to require a login")
+	}
+	if credentials.Covers([]string{drive}, "/secrets/client.json") {
+		t.Error("Expected another OAuth client to require a login")
+	}
+
+	plain := GCloudConfig{}
+	withScopes := GCloudConfig{ADCScopes: []string{drive, sheets}}
+	if plain.CredentialKey("jane@example.com") != CredentialKey("jane@example.com", "") {
+		t.Error("Expected default ADC settings to keep the account key")
+	}
+	if withScopes.CredentialKey("jane@example.com") == plain.CredentialKey("jane@example.com") {
+		t.Error("Expected custom scopes to use other credentials")
+	}
+	if (GCloudConfig{ADCScopes: []string{sheets, drive}}).CredentialKey("jane@example.com") != withScopes.CredentialKey("jane@example.com") {
+		t.Error("Expected the order of scopes not to matter")
+	}
+	if (GCloudConfig{ClientIDFile: "/secrets/client.json"}).CredentialKey("jane@example.com") == plain.CredentialKey("jane@example.com") {
+		t.Error("Expected another OAuth client to use other credentials")
+	}
+
+	if err := ValidateScopes([]string{"openid", drive}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, scope := range []string{"drive", "http://www.googleapis.com/auth/drive", drive + "," + sheets} {
+		if err := ValidateScopes([]string{scope}); err == nil {
+			t.Errorf("Expected scope '%s' to be invalid", scope)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags([]string{"env:prod,client:acme", " env:prod ", "legacy"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tags) != 3 || tags[0] != "env:prod" || tags[1] != "client:acme" || tags[2] != "legacy" {
+		t.Errorf("Unexpected tags: %v", tags)
+	}
+
+	for _, invalid := range []string{"", "env:", ":prod", "env prod"} {
+		if _, err := ParseTags([]string{invalid}); err == nil {
+			t.Errorf("Expected '%s' to be rejected", invalid)
+		}
+	}
+}
+
+func TestConfigStoreSelectByTags(t *testing.T) {
+	store := &ConfigStore{
+		Configurations: []GCloudConfig{
+			{Name: "acme-prod", Tags: []string{"client:acme", "env:prod"}},
+			{Name: "acme-dev", Tags: []string{"client:acme", "env:dev"}},
+			{Name: "other"},
+		},
+	}
+
+	selected := store.SelectByTags([]string{"client:acme", "env:prod"})
+	if len(selected) != 1 || selected[0].Name != "acme-prod" {
+		t.Errorf("Expected only acme-prod, got: %v", selected)
+	}
+	if len(store.SelectByTags(nil)) != 3 {
+		t.Error("Expected an empty selector to match every configuration")
+	}
+	if value, ok := store.Configurations[1].TagValue("env"); !ok || value != "dev" {
+		t.Errorf("Expected env value 'dev', got '%s'", value)
+	}
+
+	all := store.AllTags()
+	if len(all) != 3 || all[0] != "client:acme" || all[2] != "env:prod" {
+		t.Errorf("Expected sorted unique tags, got: %v", all)
+	}
+
+	store.Configurations[0].RemoveTags([]string{"env:prod"})
+	store.Configurations[0].AddTags([]string{"client:acme", "env:staging"})
+	if got := store.Configurations[0].Tags; len(got) != 2 || got[1] != "env:staging" {
+		t.Errorf("Unexpected tags after edit: %v", got)
+	}
+}
+
+func TestConfigStoreResolveConfig(t *testing.T) {
+	store := &ConfigStore{
+		Configurations: []GCloudConfig{
+			{Name: "acme-production-europe", Aliases: []string{"p"}},
+			{Name: "acme-staging"},
+			{Name: "globex-dev"},
+		},
+	}
+
+	for query, expected := range map[string]string{
+		"acme-staging": "acme-staging",
+		"p":            "acme-production-europe",
+		"acme-p":       "acme-production-europe",
+		"glo":          "globex-dev",
+	} {
+		cfg, err := store.ResolveConfig(query)
+		if err != nil || cfg.Name != expected {
+			t.Errorf("Expected '%s' to resolve to '%s', got: %v, %v", query, expected, cfg, err)
+		}
+	}
+
+	_, err := store.ResolveConfig("acme")
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) || !resolveErr.Ambiguous || len(resolveErr.Suggestions) != 2 {
+		t.Errorf("Expected an ambiguous prefix error, got: %v", err)
+	}
+
+	_, err = store.ResolveConfig("acme-stagign")
+	if !errors.As(err, &resolveErr) || resolveErr.Ambiguous || len(resolveErr.Suggestions) == 0 || resolveErr.Suggestions[0] != "acme-staging" {
+		t.Errorf("Expected a did-you-mean suggestion, got: %v", err)
+	}
+
+	_, err = store.ResolveConfig("unrelated")
+	if !errors.As(err, &resolveErr) || len(resolveErr.Suggestions) != 0 {
+		t.Errorf("Expected no suggestion, got: %v", err)
+	}
+
+	if !store.NameInUse("p", "") || store.NameInUse("p", "acme-production-europe") {
+		t.Error("Expected aliases to be reserved for other configurations only")
+	}
+}
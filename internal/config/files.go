@@ -0,0 +1,52 @@
+package config
+
+import (
+	"gcloud-switch/internal/dryrun"
+	"io"
+	"os"
+)
+
+// RemoveFile deletes a file managed by gcloud-switcher; a missing file is not an error
+func RemoveFile(path string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("delete %s", path)
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MoveFile renames a file managed by gcloud-switcher
+func MoveFile(srcPath, dstPath string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("move %s to %s", srcPath, dstPath)
+		return nil
+	}
+	return os.Rename(srcPath, dstPath)
+}
+
+// CopyFile copies a file, keeping the copy private as it may hold credentials
+func CopyFile(srcPath, dstPath string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("copy %s to %s", srcPath, dstPath)
+		return nil
+	}
+
+	source, err := os.Open(srcPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer source.Close() //nolint:errcheck
+
+	destination, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		_ = destination.Close() //nolint:errcheck
+		return err
+	}
+	return destination.Close()
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions caps the "did you mean" list
+const maxSuggestions = 5
+
+// ResolveError reports a configuration name that matched no configuration or several
+type ResolveError struct {
+	Query string
+	// Ambiguous is set when several configurations share the prefix
+	Ambiguous bool
+	// Suggestions lists the closest configuration names
+	Suggestions []string
+}
+
+func (e *ResolveError) Error() string {
+	switch {
+	case e.Ambiguous:
+		return fmt.Sprintf("configuration '%s' is ambiguous, did you mean: %s?", e.Query, strings.Join(e.Suggestions, ", "))
+	case len(e.Suggestions) > 0:
+		return fmt.Sprintf("configuration '%s' not found, did you mean: %s?", e.Query, strings.Join(e.Suggestions, ", "))
+	default:
+		return fmt.Sprintf("configuration '%s' not found", e.Query)
+	}
+}
+
+// ResolveConfig finds a configuration by exact name, alias, or unique name or alias prefix.
+// Otherwise it returns a *ResolveError suggesting the closest names.
+func (cs *ConfigStore) ResolveConfig(query string) (*GCloudConfig, error) {
+	if cfg, err := cs.FindConfig(query); err == nil {
+		return cfg, nil
+	}
+	for i := range cs.Configurations {
+		if slices.Contains(cs.Configurations[i].Aliases, query) {
+			return &cs.Configurations[i], nil
+		}
+	}
+
+	var prefixed []*GCloudConfig
+	for i := range cs.Configurations {
+		cfg := &cs.Configurations[i]
+		if strings.HasPrefix(cfg.Name, query) || slices.ContainsFunc(cfg.Aliases, func(alias string) bool {
+			return strings.HasPrefix(alias, query)
+		}) {
+			prefixed = append(prefixed, cfg)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil
+	}
+	if len(prefixed) > 1 {
+		names := make([]string, 0, len(prefixed))
+		for _, cfg := range prefixed {
+			names = append(names, cfg.Name)
+		}
+		sort.Strings(names)
+		return nil, &ResolveError{Query: query, Ambiguous: true, Suggestions: names}
+	}
+
+	return nil, &ResolveError{Query: query, Suggestions: cs.suggest(query)}
+}
+
+// suggest returns the configuration names closest to a mistyped query
+func (cs *ConfigStore) suggest(query string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+	limit := max(2, len(query)/3)
+
+	var matches []scored
+	for _, cfg := range cs.Configurations {
+		best := -1
+		for _, candidate := range append([]string{cfg.Name}, cfg.Aliases...) {
+			distance := levenshtein(query, candidate)
+			if isSubsequence(query, candidate) || strings.Contains(candidate, query) {
+				distance = min(distance, limit)
+			}
+			if distance <= limit && (best < 0 || distance < best) {
+				best = distance
+			}
+		}
+		if best >= 0 {
+			matches = append(matches, scored{name: cfg.Name, distance: best})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	var names []string
+	for _, match := range matches {
+		if len(names) == maxSuggestions {
+			break
+		}
+		names = append(names, match.name)
+	}
+	return names
+}
+
+// isSubsequence reports whether the characters of short appear in long in order, e.g. "acprd" in "acme-prod"
+func isSubsequence(short, long string) bool {
+	i := 0
+	for j := 0; i < len(short) && j < len(long); j++ {
+		if short[i] == long[j] {
+			i++
+		}
+	}
+	return i == len(short)
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// NameInUse reports whether a name or alias is taken by another configuration than except
+func (cs *ConfigStore) NameInUse(name, except string) bool {
+	for _, cfg := range cs.Configurations {
+		if cfg.Name == except {
+			continue
+		}
+		if cfg.Name == name || slices.Contains(cfg.Aliases, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAliases normalizes aliases given on the command line; each value may hold
+// several comma-separated aliases
+func ParseAliases(values []string) ([]string, error) {
+	var aliases []string
+	for _, value := range values {
+		for _, alias := range strings.Split(value, ",") {
+			alias = strings.TrimSpace(alias)
+			if alias == "" || strings.ContainsAny(alias, " \t\n:,") {
+				return nil, fmt.Errorf("invalid alias '%s': aliases are single words", alias)
+			}
+			if !slices.Contains(aliases, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// AddAliases adds the aliases the configuration does not have yet
+func (c *GCloudConfig) AddAliases(aliases []string) {
+	for _, alias := range aliases {
+		if !slices.Contains(c.Aliases, alias) {
+			c.Aliases = append(c.Aliases, alias)
+		}
+	}
+}
+
+// RemoveAliases removes the given aliases from the configuration
+func (c *GCloudConfig) RemoveAliases(aliases []string) {
+	c.Aliases = slices.DeleteFunc(c.Aliases, func(alias string) bool {
+		return slices.Contains(aliases, alias)
+	})
+	if len(c.Aliases) == 0 {
+		c.Aliases = nil
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"gcloud-switch/internal/dryrun"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// CredentialState is the cached result of the last credential check of a configuration
+type CredentialState struct {
+	Valid     bool      `json:"valid"`
+	CheckedAt time.Time `json:"checked_at"`
+	// ExpiresAt is when the access tokens obtained during the check expire
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// LoggedInAt is when gcloud-switcher last logged in with these credentials
+	LoggedInAt time.Time `json:"logged_in_at,omitempty"`
+	// Scopes and ClientIDFile are the extra ADC scopes and OAuth client of that login
+	Scopes       []string `json:"scopes,omitempty"`
+	ClientIDFile string   `json:"client_id_file,omitempty"`
+}
+
+// Fresh reports whether the credentials can be assumed valid at now, that is
+// they were valid and their tokens do not expire within margin
+func (c CredentialState) Fresh(now time.Time, margin time.Duration) bool {
+	return c.Valid && !c.ExpiresAt.IsZero() && now.Add(margin).Before(c.ExpiresAt)
+}
+
+// Covers reports whether the ADC of the last login was obtained with every one of scopes
+// and with clientIDFile, so it can be reused by a configuration requesting them
+func (c CredentialState) Covers(scopes []string, clientIDFile string) bool {
+	if c.ClientIDFile != clientIDFile {
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// State holds data cached between runs. Unlike the configuration store it can be
+// deleted at any time without losing anything but speed and usage history.
+type State struct {
+	// Credentials is keyed by credential key, or by configuration name while the account is unknown
+	Credentials map[string]CredentialState `json:"credentials,omitempty"`
+	// Identities maps configuration names to the credential key they last used
+	Identities map[string]string `json:"identities,omitempty"`
+	// LastUsed records when each configuration was last switched to
+	LastUsed map[string]time.Time `json:"last_used,omitempty"`
+}
+
+func newState() *State {
+	return &State{Credentials: map[string]CredentialState{}, Identities: map[string]string{}, LastUsed: map[string]time.Time{}}
+}
+
+// GetStatePath returns the path to the state file
+func GetStatePath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), "state.json"), nil
+}
+
+// LoadState loads the cached state; a missing or unreadable file yields an empty state
+func LoadState() *State {
+	state := newState()
+
+	statePath, err := GetStatePath()
+	if err != nil {
+		return state
+	}
+	data, err := os.ReadFile(statePath) //nolint:gosec
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return newState()
+	}
+	if state.Credentials == nil {
+		state.Credentials = map[string]CredentialState{}
+	}
+	if state.Identities == nil {
+		state.Identities = map[string]string{}
+	}
+	if state.LastUsed == nil {
+		state.LastUsed = map[string]time.Time{}
+	}
+	return state
+}
+
+// Save saves the state to disk. Nothing is written in dry-run mode.
+func (s *State) Save() error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	statePath, err := GetStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0600)
+}
+
+// CredentialKey returns the key the credentials of a configuration are cached under:
+// the credential key it last used, or its name
+func (s *State) CredentialKey(name string) string {
+	if key, ok := s.Identities[name]; ok {
+		return key
+	}
+	return name
+}
+
+// BindIdentity records the credential key used by a configuration, so configurations
+// with the same account share the cached check
+func (s *State) BindIdentity(name, key string) {
+	if key == "" || key == s.Identities[name] {
+		return
+	}
+	// A check cached under the name is superseded by the shared one
+	delete(s.Credentials, name)
+	s.Identities[name] = key
+}
+
+// RecordCredentials caches the outcome of a credential check; expiresAt may be zero when unknown
+func (s *State) RecordCredentials(name string, valid bool, checkedAt, expiresAt time.Time) {
+	credentials := s.Credentials[name]
+	credentials.Valid, credentials.CheckedAt, credentials.ExpiresAt = valid, checkedAt, expiresAt
+	s.Credentials[name] = credentials
+}
+
+// RecordLogin records a login with the credentials cached under key and the extra ADC
+// scopes and OAuth client it used
+func (s *State) RecordLogin(key string, at time.Time, scopes []string, clientIDFile string) {
+	credentials := s.Credentials[key]
+	credentials.LoggedInAt = at
+	credentials.Scopes, credentials.ClientIDFile = slices.Clone(scopes), clientIDFile
+	s.Credentials[key] = credentials
+}
+
+// RecordUse records that a configuration was switched to
+func (s *State) RecordUse(name string, at time.Time) {
+	s.LastUsed[name] = at
+}
+
+// RenameCredentials moves the cached credential state of a configuration to a new name
+func (s *State) RenameCredentials(oldName, newName string) {
+	if credentials, ok := s.Credentials[oldName]; ok {
+		s.Credentials[newName] = credentials
+		delete(s.Credentials, oldName)
+	}
+	if key, ok := s.Identities[oldName]; ok {
+		s.Identities[newName] = key
+		delete(s.Identities, oldName)
+	}
+	if lastUsed, ok := s.LastUsed[oldName]; ok {
+		s.LastUsed[newName] = lastUsed
+		delete(s.LastUsed, oldName)
+	}
+}
+
+// ForgetCredentials drops the cached credential state of a configuration. A check shared
+// with other configurations is kept.
+func (s *State) ForgetCredentials(name string) {
+	delete(s.Credentials, name)
+	delete(s.Identities, name)
+	delete(s.LastUsed, name)
+}
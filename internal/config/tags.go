@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ParseTags normalizes tags given on the command line. Each value may hold several
+// comma-separated tags such as "env:prod,client:acme"; duplicates are dropped.
+func ParseTags(values []string) ([]string, error) {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if err := validateTag(tag); err != nil {
+				return nil, err
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags, nil
+}
+
+func validateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("empty tag")
+	}
+	if strings.ContainsAny(tag, " \t\n") {
+		return fmt.Errorf("invalid tag '%s': tags cannot contain whitespace", tag)
+	}
+	if key, value, found := strings.Cut(tag, ":"); found && (key == "" || value == "") {
+		return fmt.Errorf("invalid tag '%s': expected key:value or a single word", tag)
+	}
+	return nil
+}
+
+// HasTag reports whether the configuration carries the tag
+func (c GCloudConfig) HasTag(tag string) bool {
+	return slices.Contains(c.Tags, tag)
+}
+
+// TagValue returns the value of the key:value tag with the given key
+func (c GCloudConfig) TagValue(key string) (string, bool) {
+	for _, tag := range c.Tags {
+		if k, v, found := strings.Cut(tag, ":"); found && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// MatchesTags reports whether the configuration carries every one of the tags
+func (c GCloudConfig) MatchesTags(tags []string) bool {
+	for _, tag := range tags {
+		if !c.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTags adds the tags the configuration does not carry yet
+func (c *GCloudConfig) AddTags(tags []string) {
+	for _, tag := range tags {
+		if !c.HasTag(tag) {
+			c.Tags = append(c.Tags, tag)
+		}
+	}
+}
+
+// RemoveTags removes the given tags from the configuration
+func (c *GCloudConfig) RemoveTags(tags []string) {
+	c.Tags = slices.DeleteFunc(c.Tags, func(tag string) bool {
+		return slices.Contains(tags, tag)
+	})
+	if len(c.Tags) == 0 {
+		c.Tags = nil
+	}
+}
+
+// SelectByTags returns the configurations carrying every one of the tags
+func (cs *ConfigStore) SelectByTags(tags []string) []*GCloudConfig {
+	var selected []*GCloudConfig
+	for i := range cs.Configurations {
+		if cs.Configurations[i].MatchesTags(tags) {
+			selected = append(selected, &cs.Configurations[i])
+		}
+	}
+	return selected
+}
+
+// AllTags returns every tag used by a configuration, sorted
+func (cs *ConfigStore) AllTags() []string {
+	var tags []string
+	for _, cfg := range cs.Configurations {
+		for _, tag := range cfg.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// projectIDPattern matches project IDs: 6 to 30 lowercase letters, digits and hyphens,
+	// starting with a letter and not ending with a hyphen. Legacy domain-scoped projects
+	// are prefixed with "example.com:".
+	projectIDPattern = regexp.MustCompile(`^([a-z0-9.-]+\.[a-z]+:)?[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	// serviceAccountPattern matches service account emails, including the Google-managed
+	// default accounts (e.g. 123-compute@developer.gserviceaccount.com)
+	serviceAccountPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,98}@([a-z0-9.:-]+\.)?gserviceaccount\.com$`)
+	// accountPattern loosely matches the email of a user account
+	accountPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// ValidateProjectID checks the syntax of a project ID
+func ValidateProjectID(projectID string) error {
+	if projectID == "" {
+		return fmt.Errorf("project ID is required")
+	}
+	if !projectIDPattern.MatchString(projectID) {
+		hint := "project IDs have 6 to 30 lowercase letters, digits or hyphens, start with a letter and do not end with a hyphen"
+		if strings.ToLower(projectID) != projectID {
+			hint = "project IDs are lowercase"
+		}
+		return fmt.Errorf("invalid project ID '%s': %s", projectID, hint)
+	}
+	return nil
+}
+
+// ValidateServiceAccount checks the syntax of a service account email; empty means none
+func ValidateServiceAccount(email string) error {
+	if email == "" {
+		return nil
+	}
+	if !serviceAccountPattern.MatchString(email) {
+		return fmt.Errorf("invalid service account '%s': expected an email such as name@project-id.iam.gserviceaccount.com", email)
+	}
+	return nil
+}
+
+// ValidateDelegates checks the service accounts impersonated before serviceAccount
+func ValidateDelegates(delegates []string, serviceAccount string) error {
+	if len(delegates) > 0 && serviceAccount == "" {
+		return fmt.Errorf("delegates require a service account to impersonate")
+	}
+	seen := map[string]bool{serviceAccount: true}
+	for _, delegate := range delegates {
+		if err := ValidateServiceAccount(delegate); err != nil || delegate == "" {
+			return fmt.Errorf("invalid delegate '%s': expected a service account email", delegate)
+		}
+		if seen[delegate] {
+			return fmt.Errorf("service account '%s' appears twice in the impersonation chain", delegate)
+		}
+		seen[delegate] = true
+	}
+	return nil
+}
+
+// ValidateScopes checks OAuth scopes, which are URLs apart from a few OpenID Connect names
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		switch {
+		case scope == "openid" || scope == "email" || scope == "profile":
+		case strings.HasPrefix(scope, "https://") && !strings.ContainsAny(scope, " ,"):
+		default:
+			return fmt.Errorf("invalid scope '%s': expected a URL such as https://www.googleapis.com/auth/drive", scope)
+		}
+	}
+	return nil
+}
+
+// ValidateAccount checks the syntax of a user account email; empty means any account
+func ValidateAccount(email string) error {
+	if email == "" {
+		return nil
+	}
+	if !accountPattern.MatchString(email) {
+		return fmt.Errorf("invalid account '%s': expected an email such as jane@example.com", email)
+	}
+	return nil
+}
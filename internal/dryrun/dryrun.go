@@ -0,0 +1,49 @@
+// Package dryrun records the mutating operations that would be performed when --dry-run is set.
+package dryrun
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	mu         sync.Mutex
+	enabled    bool
+	operations []string
+)
+
+// Enable turns dry-run mode on: mutating operations must be recorded instead of executed
+func Enable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = true
+}
+
+// Enabled reports whether dry-run mode is on
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// Record appends an operation to the plan
+func Record(format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	operations = append(operations, fmt.Sprintf(format, args...))
+}
+
+// Operations returns the recorded operations in order
+func Operations() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), operations...)
+}
+
+// Reset turns dry-run mode off and forgets the recorded operations
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = false
+	operations = nil
+}
@@ -0,0 +1,31 @@
+package dryrun
+
+import "testing"
+
+func TestRecord(t *testing.T) {
+	defer Reset()
+
+	if Enabled() {
+		t.Error("Expected dry-run mode to be off by default")
+	}
+	Enable()
+	if !Enabled() {
+		t.Error("Expected dry-run mode to be on after Enable")
+	}
+
+	Record("gcloud %s", "config configurations activate dev")
+	Record("write %s", "config.json")
+
+	operations := Operations()
+	if len(operations) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(operations))
+	}
+	if operations[0] != "gcloud config configurations activate dev" {
+		t.Errorf("Expected operations in order, got: %v", operations)
+	}
+
+	Reset()
+	if Enabled() || len(Operations()) != 0 {
+		t.Error("Expected Reset to turn dry-run mode off and clear operations")
+	}
+}
@@ -0,0 +1,88 @@
+package gcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ADC credential types written by gcloud
+const (
+	ADCAuthorizedUser      = "authorized_user"
+	ADCImpersonated        = "impersonated_service_account"
+	ADCServiceAccountKey   = "service_account"
+	impersonationURLPrefix = "/serviceAccounts/"
+	impersonationURLSuffix = ":generateAccessToken"
+)
+
+// ADCIdentity describes whose credentials an ADC file holds
+type ADCIdentity struct {
+	// Type is the credential type, e.g. authorized_user or impersonated_service_account
+	Type string
+	// Account is the user account, when gcloud recorded it
+	Account string
+	// ServiceAccount is the impersonated service account, or the client email of a key
+	ServiceAccount string
+	// Delegates are the service accounts impersonated in turn before ServiceAccount
+	Delegates []string
+}
+
+// ReadADCIdentity reads the identity of the live ADC file
+func ReadADCIdentity() (ADCIdentity, error) {
+	adcPath, err := GetADCPath()
+	if err != nil {
+		return ADCIdentity{}, err
+	}
+	return ReadADCIdentityFile(adcPath)
+}
+
+// ReadADCIdentityFile reads the identity of an ADC file, such as a saved one
+func ReadADCIdentityFile(path string) (ADCIdentity, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return ADCIdentity{}, fmt.Errorf("failed to read ADC file: %w", err)
+	}
+	return parseADCIdentity(data)
+}
+
+// parseADCIdentity extracts the identity from the content of an ADC file
+func parseADCIdentity(data []byte) (ADCIdentity, error) {
+	var file struct {
+		Type                           string   `json:"type"`
+		Account                        string   `json:"account"`
+		ClientEmail                    string   `json:"client_email"`
+		ServiceAccountImpersonationURL string   `json:"service_account_impersonation_url"`
+		Delegates                      []string `json:"delegates"`
+		SourceCredentials              struct {
+			Account string `json:"account"`
+		} `json:"source_credentials"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ADCIdentity{}, fmt.Errorf("failed to parse ADC file: %w", err)
+	}
+
+	identity := ADCIdentity{Type: file.Type}
+	switch file.Type {
+	case ADCAuthorizedUser:
+		identity.Account = file.Account
+	case ADCImpersonated:
+		identity.Account = file.SourceCredentials.Account
+		url := file.ServiceAccountImpersonationURL
+		if i := strings.LastIndex(url, impersonationURLPrefix); i >= 0 {
+			identity.ServiceAccount = strings.TrimSuffix(url[i+len(impersonationURLPrefix):], impersonationURLSuffix)
+		}
+		// Delegates are emails or resource names such as projects/-/serviceAccounts/EMAIL
+		for _, delegate := range file.Delegates {
+			if i := strings.LastIndex(delegate, impersonationURLPrefix); i >= 0 {
+				delegate = delegate[i+len(impersonationURLPrefix):]
+			}
+			identity.Delegates = append(identity.Delegates, delegate)
+		}
+	case ADCServiceAccountKey:
+		identity.ServiceAccount = file.ClientEmail
+	default:
+		return ADCIdentity{}, fmt.Errorf("unsupported ADC type '%s'", file.Type)
+	}
+	return identity, nil
+}
@@ -1,21 +1,80 @@
 package gcloud
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"gcloud-switch/internal/dryrun"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 )
 
+// GetConfigDir returns the gcloud configuration directory, honoring CLOUDSDK_CONFIG
+func GetConfigDir() (string, error) {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud"), nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "gcloud"), nil
+}
+
 // GetADCPath returns the standard location of the ADC file
 func GetADCPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "application_default_credentials.json"), nil
+}
+
+// ReadActiveConfigurationName returns the active gcloud configuration by reading gcloud's
+// state directly, without spawning gcloud. CLOUDSDK_ACTIVE_CONFIG_NAME takes precedence.
+func ReadActiveConfigurationName() (string, error) {
+	if name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME"); name != "" {
+		return name, nil
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "active_config")) //nolint:gosec
+	if os.IsNotExist(err) {
+		// gcloud falls back to the default configuration
+		return "default", nil
+	}
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(homeDir, ".config", "gcloud", "application_default_credentials.json"), nil
+	return strings.TrimSpace(string(data)), nil
+}
+
+// IsInstalled checks if the gcloud binary can be found in PATH
+func IsInstalled() bool {
+	_, err := exec.LookPath("gcloud")
+	return err == nil
+}
+
+// GetVersion returns the installed Google Cloud SDK version
+func GetVersion(ctx context.Context) (string, error) {
+	output, err := query(ctx, "version", "--format=value(\"Google Cloud SDK\")")
+	if err != nil {
+		return "", fmt.Errorf("failed to get gcloud version: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
 }
 
 // SaveADC saves the current ADC file to a specified location
@@ -31,6 +90,11 @@ func SaveADC(destPath string) error {
 		return nil
 	}
 
+	if dryrun.Enabled() {
+		dryrun.Record("copy %s to %s", adcPath, destPath)
+		return nil
+	}
+
 	// Copy the ADC file
 	source, err := os.Open(adcPath)
 	if err != nil {
@@ -38,7 +102,8 @@ func SaveADC(destPath string) error {
 	}
 	defer source.Close()
 
-	destination, err := os.Create(destPath)
+	// Saved credentials contain refresh tokens, keep them private
+	destination, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) //nolint:gosec
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
@@ -65,6 +130,11 @@ func RestoreADC(sourcePath string) error {
 		return err
 	}
 
+	if dryrun.Enabled() {
+		dryrun.Record("copy %s to %s", sourcePath, adcPath)
+		return nil
+	}
+
 	// Ensure the directory exists
 	adcDir := filepath.Dir(adcPath)
 	if err := os.MkdirAll(adcDir, 0755); err != nil {
@@ -105,40 +175,101 @@ func DeleteADC() error {
 		return nil
 	}
 
+	if dryrun.Enabled() {
+		dryrun.Record("delete %s", adcPath)
+		return nil
+	}
+
 	return os.Remove(adcPath)
 }
 
-// ActivateConfiguration activates a gcloud configuration by name
-func ActivateConfiguration(configName string) error {
-	cmd := exec.Command("gcloud", "config", "configurations", "activate", configName)
-	output, err := cmd.CombinedOutput()
+// ADCSnapshot holds the content of the current ADC file at a point in time
+type ADCSnapshot struct {
+	data   []byte
+	exists bool
+}
+
+// SnapshotADC captures the current ADC file so it can be put back with Restore
+func SnapshotADC() (ADCSnapshot, error) {
+	adcPath, err := GetADCPath()
+	if err != nil {
+		return ADCSnapshot{}, err
+	}
+	data, err := os.ReadFile(adcPath) //nolint:gosec
+	if os.IsNotExist(err) {
+		return ADCSnapshot{}, nil
+	}
+	if err != nil {
+		return ADCSnapshot{}, fmt.Errorf("failed to read ADC file: %w", err)
+	}
+	return ADCSnapshot{data: data, exists: true}, nil
+}
+
+// Restore puts the captured ADC file back, deleting the current one if there was none
+func (s ADCSnapshot) Restore() error {
+	if !s.exists {
+		return DeleteADC()
+	}
+
+	adcPath, err := GetADCPath()
 	if err != nil {
-		return fmt.Errorf("failed to activate configuration: %w\nOutput: %s", err, output)
+		return err
+	}
+	if dryrun.Enabled() {
+		dryrun.Record("restore previous %s", adcPath)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(adcPath), 0755); err != nil {
+		return fmt.Errorf("failed to create ADC directory: %w", err)
+	}
+	if err := os.WriteFile(adcPath, s.data, 0600); err != nil {
+		return fmt.Errorf("failed to restore ADC file: %w", err)
+	}
+	return nil
+}
+
+// ActivateConfiguration activates a gcloud configuration by name
+func ActivateConfiguration(ctx context.Context, configName string) error {
+	if err := mutate(ctx, "config", "configurations", "activate", configName); err != nil {
+		return fmt.Errorf("failed to activate configuration: %w", err)
 	}
 	return nil
 }
 
 // CreateConfiguration creates a new gcloud configuration
-func CreateConfiguration(configName string) error {
-	cmd := exec.Command("gcloud", "config", "configurations", "create", configName, "--no-activate")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create configuration: %w\nOutput: %s", err, output)
+func CreateConfiguration(ctx context.Context, configName string) error {
+	if err := mutate(ctx, "config", "configurations", "create", configName, "--no-activate"); err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+	return nil
+}
+
+// DeleteConfiguration deletes a gcloud configuration, which must not be active
+func DeleteConfiguration(ctx context.Context, configName string) error {
+	if err := mutate(ctx, "config", "configurations", "delete", configName, "--quiet"); err != nil {
+		return fmt.Errorf("failed to delete configuration: %w", err)
 	}
 	return nil
 }
 
 // ConfigurationExists checks if a gcloud configuration exists
-func ConfigurationExists(configName string) bool {
-	cmd := exec.Command("gcloud", "config", "configurations", "describe", configName)
-	err := cmd.Run()
+func ConfigurationExists(ctx context.Context, configName string) bool {
+	_, err := query(ctx, "config", "configurations", "describe", configName)
 	return err == nil
 }
 
+// ListConfigurations returns the names of all native gcloud configurations
+func ListConfigurations(ctx context.Context) ([]string, error) {
+	output, err := query(ctx, "config", "configurations", "list", "--format=value(name)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configurations: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
 // GetActiveConfiguration returns the name of the currently active gcloud configuration
-func GetActiveConfiguration() (string, error) {
-	cmd := exec.Command("gcloud", "config", "configurations", "list", "--filter=is_active:true", "--format=value(name)")
-	output, err := cmd.Output()
+func GetActiveConfiguration(ctx context.Context) (string, error) {
+	output, err := query(ctx, "config", "configurations", "list", "--filter=is_active:true", "--format=value(name)")
 	if err != nil {
 		return "", fmt.Errorf("failed to get active configuration: %w", err)
 	}
@@ -146,9 +277,8 @@ func GetActiveConfiguration() (string, error) {
 }
 
 // GetAccountFromConfiguration gets the account from a specific gcloud configuration
-func GetAccountFromConfiguration(configName string) (string, error) {
-	cmd := exec.Command("gcloud", "config", "configurations", "describe", configName, "--format=value(properties.core.account)")
-	output, err := cmd.Output()
+func GetAccountFromConfiguration(ctx context.Context, configName string) (string, error) {
+	output, err := query(ctx, "config", "configurations", "describe", configName, "--format=value(properties.core.account)")
 	if err != nil {
 		return "", fmt.Errorf("failed to get account from configuration: %w", err)
 	}
@@ -156,9 +286,8 @@ func GetAccountFromConfiguration(configName string) (string, error) {
 }
 
 // GetProjectFromConfiguration gets the project ID from a specific gcloud configuration
-func GetProjectFromConfiguration(configName string) (string, error) {
-	cmd := exec.Command("gcloud", "config", "configurations", "describe", configName, "--format=value(properties.core.project)")
-	output, err := cmd.Output()
+func GetProjectFromConfiguration(ctx context.Context, configName string) (string, error) {
+	output, err := query(ctx, "config", "configurations", "describe", configName, "--format=value(properties.core.project)")
 	if err != nil {
 		return "", fmt.Errorf("failed to get project from configuration: %w", err)
 	}
@@ -166,69 +295,353 @@ func GetProjectFromConfiguration(configName string) (string, error) {
 }
 
 // SetProject sets the active GCloud project
-func SetProject(projectID string) error {
+func SetProject(ctx context.Context, projectID string) error {
 	// Use --no-user-output-enabled to prevent interactive prompts
-	cmd := exec.Command("gcloud", "config", "set", "project", projectID, "--quiet")
-	output, err := cmd.CombinedOutput()
+	if err := mutate(ctx, "config", "set", "project", projectID, "--quiet"); err != nil {
+		return fmt.Errorf("failed to set project: %w", err)
+	}
+	return nil
+}
+
+// SetProjectForConfiguration sets the project of a gcloud configuration without activating it
+func SetProjectForConfiguration(ctx context.Context, configName, projectID string) error {
+	return SetConfigurationProperty(ctx, configName, "core/project", projectID)
+}
+
+// GetConfigurationProperties returns the properties set on a gcloud configuration, keyed as section/name
+func GetConfigurationProperties(ctx context.Context, configName string) (map[string]string, error) {
+	output, err := query(ctx, "config", "configurations", "describe", configName, "--format=json")
 	if err != nil {
-		return fmt.Errorf("failed to set project: %w\nOutput: %s", err, output)
+		return nil, fmt.Errorf("failed to describe configuration: %w", err)
+	}
+
+	var described struct {
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(output, &described); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	properties := make(map[string]string)
+	for section, values := range described.Properties {
+		for name, value := range values {
+			properties[section+"/"+name] = fmt.Sprint(value)
+		}
+	}
+	return properties, nil
+}
+
+// SetConfigurationProperty sets a property (section/name) on a gcloud configuration without activating it
+func SetConfigurationProperty(ctx context.Context, configName, property, value string) error {
+	if err := mutate(ctx, "config", "set", property, value, "--configuration", configName, "--quiet"); err != nil {
+		return fmt.Errorf("failed to set %s: %w", property, err)
+	}
+	return nil
+}
+
+// UnsetConfigurationProperty removes a property from a gcloud configuration
+func UnsetConfigurationProperty(ctx context.Context, configName, property string) error {
+	if err := mutate(ctx, "config", "unset", property, "--configuration", configName, "--quiet"); err != nil {
+		return fmt.Errorf("failed to unset %s: %w", property, err)
+	}
+	return nil
+}
+
+// CopyConfiguration creates a new gcloud configuration holding the given properties.
+// The configuration is deleted again if one of the properties cannot be set.
+func CopyConfiguration(ctx context.Context, configName string, properties map[string]string) error {
+	if err := CreateConfiguration(ctx, configName); err != nil {
+		return err
+	}
+	for property, value := range properties {
+		if err := SetConfigurationProperty(ctx, configName, property, value); err != nil {
+			_ = DeleteConfiguration(ctx, configName) //nolint:errcheck
+			return err
+		}
 	}
 	return nil
 }
 
 // AuthLogin performs a standard gcloud auth login with ADC update
-func AuthLogin() error {
-	cmd := exec.Command("gcloud", "auth", "login", "--update-adc")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+func AuthLogin(ctx context.Context, opts LoginOptions) error {
+	if !opts.customADC() {
+		if err := interactive(ctx, opts.userLoginArgs("--update-adc")...); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+		return nil
+	}
+	// Custom scopes or an OAuth client need a separate ADC login
+	if err := interactive(ctx, opts.userLoginArgs()...); err != nil {
 		return fmt.Errorf("failed to authenticate: %w", err)
 	}
+	if err := interactive(ctx, opts.adcLoginArgs()...); err != nil {
+		return fmt.Errorf("failed to set up application default credentials: %w", err)
+	}
 	return nil
 }
 
-// AuthLoginWithServiceAccount performs authentication and sets up ADC for service account impersonation
-func AuthLoginWithServiceAccount(serviceAccount string) error {
+// AuthLoginWithServiceAccount performs authentication and sets up ADC for service account impersonation.
+// chain is the service account, or a comma-separated list of delegates ending with it.
+func AuthLoginWithServiceAccount(ctx context.Context, chain string, opts LoginOptions) error {
 	// First, ensure user is logged in
-	cmd := exec.Command("gcloud", "auth", "login")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := interactive(ctx, opts.userLoginArgs()...); err != nil {
 		return fmt.Errorf("failed to authenticate: %w", err)
 	}
 	// Then set up ADC with impersonation
-	cmd = exec.Command("gcloud", "auth", "application-default", "login", "--impersonate-service-account", serviceAccount)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := interactive(ctx, opts.adcLoginArgs("--impersonate-service-account", chain)...); err != nil {
 		return fmt.Errorf("failed to set up service account impersonation: %w", err)
 	}
 	return nil
 }
 
+// RevokeAccount revokes the credentials of a user account
+func RevokeAccount(ctx context.Context, account string) error {
+	if err := mutate(ctx, "auth", "revoke", account, "--quiet"); err != nil {
+		return fmt.Errorf("failed to revoke account: %w", err)
+	}
+	return nil
+}
+
+// RevokeADC revokes the current Application Default Credentials and deletes the ADC file
+func RevokeADC(ctx context.Context) error {
+	if err := mutate(ctx, "auth", "application-default", "revoke", "--quiet"); err != nil {
+		return fmt.Errorf("failed to revoke ADC: %w", err)
+	}
+	return nil
+}
+
+// RevokeADCFile revokes the credentials of a saved ADC file without touching the current ADC.
+// The file is copied to a scratch gcloud config directory so only that copy is consumed.
+func RevokeADCFile(ctx context.Context, path string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("gcloud auth application-default revoke (saved ADC %s)", path)
+		return nil
+	}
+
+	scratchDir, err := os.MkdirTemp("", "gcloud-switcher-revoke-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratchDir) //nolint:errcheck
+
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to read saved ADC file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(scratchDir, "application_default_credentials.json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to prepare ADC revocation: %w", err)
+	}
+
+	inv := Invocation{
+		Args:     []string{"auth", "application-default", "revoke", "--quiet"},
+		Env:      []string{"CLOUDSDK_CONFIG=" + scratchDir},
+		Mutating: true,
+		Timeout:  MutateTimeout,
+	}
+	if _, err := run(ctx, inv); err != nil {
+		return fmt.Errorf("failed to revoke ADC: %w", err)
+	}
+	return nil
+}
+
+// GetActiveAccount returns the account gcloud currently uses for the active configuration
+func GetActiveAccount(ctx context.Context) (string, error) {
+	output, err := query(ctx, "config", "get-value", "account")
+	if err != nil {
+		return "", fmt.Errorf("failed to get active account: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // GetCurrentProject returns the currently active project
-func GetCurrentProject() (string, error) {
-	cmd := exec.Command("gcloud", "config", "get-value", "project")
-	output, err := cmd.Output()
+func GetCurrentProject(ctx context.Context) (string, error) {
+	output, err := query(ctx, "config", "get-value", "project")
 	if err != nil {
 		return "", fmt.Errorf("failed to get current project: %w", err)
 	}
 	return string(output), nil
 }
 
+// defaultTokenLifetime is the lifetime of Google OAuth2 access tokens, assumed when gcloud does not print the expiry
+const defaultTokenLifetime = time.Hour
+
+// AccessToken is an OAuth2 access token printed by gcloud
+type AccessToken struct {
+	Token  string
+	Expiry time.Time
+}
+
+// PrintAccessToken returns an access token for the active account
+func PrintAccessToken(ctx context.Context) (AccessToken, error) {
+	output, err := query(ctx, "auth", "print-access-token", "--format=json")
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return parseAccessToken(output, time.Now())
+}
+
+// PrintADCAccessToken returns an access token from the Application Default Credentials
+func PrintADCAccessToken(ctx context.Context) (AccessToken, error) {
+	output, err := query(ctx, "auth", "application-default", "print-access-token", "--format=json")
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("failed to get ADC access token: %w", err)
+	}
+	return parseAccessToken(output, time.Now())
+}
+
+// PrintAccessTokenForConfiguration returns an access token for the account of a
+// configuration without activating it
+func PrintAccessTokenForConfiguration(ctx context.Context, configName string) (AccessToken, error) {
+	output, err := query(ctx, "auth", "print-access-token", "--configuration", configName, "--format=json")
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return parseAccessToken(output, time.Now())
+}
+
+// PrintADCFileAccessToken returns an access token from a saved ADC file without touching the
+// current ADC, for the given OAuth scopes when any
+func PrintADCFileAccessToken(ctx context.Context, path string, scopes ...string) (AccessToken, error) {
+	args := []string{"auth", "application-default", "print-access-token", "--format=json"}
+	if len(scopes) > 0 {
+		args = append(args, "--scopes", strings.Join(scopes, ","))
+	}
+	output, err := run(ctx, Invocation{
+		Args:    args,
+		Env:     []string{"GOOGLE_APPLICATION_CREDENTIALS=" + path},
+		Timeout: QueryTimeout,
+	})
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("failed to get ADC access token: %w", err)
+	}
+	return parseAccessToken(output, time.Now())
+}
+
+// PrintIdentityToken returns an ID token for the account of a configuration, or for the
+// service account it impersonates through chain. gcloud only sets the audience of service account tokens.
+func PrintIdentityToken(ctx context.Context, configName, chain, audience string) (string, error) {
+	args := []string{"auth", "print-identity-token", "--configuration", configName}
+	if chain != "" {
+		args = append(args, "--impersonate-service-account", chain, "--include-email")
+		if audience != "" {
+			args = append(args, "--audiences", audience)
+		}
+	}
+	output, err := query(ctx, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get identity token: %w", err)
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return "", fmt.Errorf("empty identity token")
+	}
+	return token, nil
+}
+
+// IdentityTokenExpiry returns the expiry in the exp claim of an ID token
+func IdentityTokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("identity token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode identity token: %w", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("identity token has no expiry")
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// parseAccessToken parses the output of print-access-token, which is either a JSON
+// object with the token and its expiry or, on older gcloud versions, the bare token
+func parseAccessToken(output []byte, now time.Time) (AccessToken, error) {
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed == "" {
+		return AccessToken{}, fmt.Errorf("empty access token")
+	}
+
+	var printed struct {
+		Token  string `json:"token"`
+		Expiry string `json:"expiry"`
+	}
+	if err := json.Unmarshal([]byte(trimmed), &printed); err != nil || printed.Token == "" {
+		return AccessToken{Token: trimmed, Expiry: now.Add(defaultTokenLifetime)}, nil
+	}
+
+	token := AccessToken{Token: printed.Token, Expiry: now.Add(defaultTokenLifetime)}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if expiry, err := time.Parse(layout, printed.Expiry); err == nil {
+			token.Expiry = expiry
+			break
+		}
+	}
+	return token, nil
+}
+
 // CheckADCValid checks if Application Default Credentials are still valid
-func CheckADCValid() bool {
-	cmd := exec.Command("gcloud", "auth", "application-default", "print-access-token")
-	err := cmd.Run()
+func CheckADCValid(ctx context.Context) bool {
+	_, err := PrintADCAccessToken(ctx)
 	return err == nil
 }
 
 // CheckAccountValid checks if the account credentials are still valid
-func CheckAccountValid() bool {
-	cmd := exec.Command("gcloud", "auth", "print-access-token")
-	err := cmd.Run()
+func CheckAccountValid(ctx context.Context) bool {
+	_, err := PrintAccessToken(ctx)
 	return err == nil
 }
+
+// VerifyProject confirms that the project exists and is accessible by the active account
+func VerifyProject(ctx context.Context, projectID string) error {
+	if _, err := query(ctx, "projects", "describe", projectID, "--format=value(projectId)"); err != nil {
+		return explainAccessError(err, fmt.Sprintf("project '%s'", projectID),
+			"roles/browser (resourcemanager.projects.get) on the project")
+	}
+	return nil
+}
+
+// GetProjectNumber returns the numeric ID of a project
+func GetProjectNumber(ctx context.Context, projectID string) (string, error) {
+	output, err := query(ctx, "projects", "describe", projectID, "--format=value(projectNumber)")
+	if err != nil {
+		return "", fmt.Errorf("failed to get project number: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// VerifyServiceAccount confirms that the service account exists
+func VerifyServiceAccount(ctx context.Context, email string) error {
+	if _, err := query(ctx, "iam", "service-accounts", "describe", email, "--format=value(email)"); err != nil {
+		return explainAccessError(err, fmt.Sprintf("service account '%s'", email),
+			"roles/iam.serviceAccountViewer (iam.serviceAccounts.get) on the service account or its project")
+	}
+	return nil
+}
+
+// VerifyImpersonation confirms that the active account can mint tokens for the service
+// account, or through every hop of a comma-separated chain of delegates ending with it
+func VerifyImpersonation(ctx context.Context, chain string) error {
+	if _, err := query(ctx, "auth", "print-access-token", "--impersonate-service-account="+chain); err != nil {
+		return explainAccessError(err, fmt.Sprintf("impersonation of '%s'", chain),
+			"roles/iam.serviceAccountTokenCreator (iam.serviceAccounts.getAccessToken) on the service account and each delegate for the one before it")
+	}
+	return nil
+}
+
+// explainAccessError turns a failed gcloud check into an error naming the missing IAM role
+func explainAccessError(err error, subject, role string) error {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "PERMISSION_DENIED"), strings.Contains(message, "does not have permission"),
+		strings.Contains(message, "Permission denied"), strings.Contains(message, "403"):
+		return fmt.Errorf("%s is not permitted for the active account; it needs %s: %w", subject, role, err)
+	case strings.Contains(message, "NOT_FOUND"), strings.Contains(message, "not found"), strings.Contains(message, "404"):
+		return fmt.Errorf("%s was not found: %w", subject, err)
+	default:
+		return fmt.Errorf("failed to verify %s: %w", subject, err)
+	}
+}
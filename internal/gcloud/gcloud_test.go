@@ -1,22 +1,247 @@
 package gcloud
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"gcloud-switch/internal/dryrun"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCheckADCValid(t *testing.T) {
-	result := CheckADCValid()
+	result := CheckADCValid(context.Background())
 	if result != true && result != false {
 		t.Error("CheckADCValid should return a boolean value")
 	}
 }
 func TestGCloudFunctionsExist(t *testing.T) {
-	err := SetProject("test-project")
+	err := SetProject(context.Background(), "test-project")
 	_ = err
-	_, err = GetCurrentProject()
+	_, err = GetCurrentProject(context.Background())
 	_ = err
-	valid := CheckADCValid()
+	valid := CheckADCValid(context.Background())
 	if valid != true && valid != false {
 		t.Error("CheckADCValid should return a boolean")
 	}
 }
+
+// recordingRunner records every invocation and succeeds with empty output
+type recordingRunner struct {
+	invocations []Invocation
+}
+
+func (r *recordingRunner) Run(ctx context.Context, inv Invocation) ([]byte, error) {
+	r.invocations = append(r.invocations, inv)
+	return nil, nil
+}
+
+func TestDryRunRecordsMutatingInvocations(t *testing.T) {
+	fake := &recordingRunner{}
+	previous := SetRunner(fake)
+	defer SetRunner(previous)
+	dryrun.Enable()
+	defer dryrun.Reset()
+
+	if err := ActivateConfiguration(context.Background(), "dev"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !ConfigurationExists(context.Background(), "dev") {
+		t.Error("Expected read-only check to reach the runner")
+	}
+
+	if len(fake.invocations) != 1 || fake.invocations[0].Mutating {
+		t.Fatalf("Expected only the read-only invocation to be executed, got: %+v", fake.invocations)
+	}
+	operations := dryrun.Operations()
+	if len(operations) != 1 || operations[0] != "gcloud config configurations activate dev" {
+		t.Errorf("Expected activation to be recorded, got: %v", operations)
+	}
+}
+
+// blockingRunner waits until the invocation's context is done
+type blockingRunner struct {
+	deadlines []bool
+}
+
+func (r *blockingRunner) Run(ctx context.Context, inv Invocation) ([]byte, error) {
+	_, hasDeadline := ctx.Deadline()
+	r.deadlines = append(r.deadlines, hasDeadline)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunHonorsTimeoutAndCancellation(t *testing.T) {
+	fake := &blockingRunner{}
+	previous := SetRunner(fake)
+	defer SetRunner(previous)
+
+	_, err := run(context.Background(), Invocation{Args: []string{"info"}, Timeout: 10 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := interactive(ctx, "auth", "login"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation, got: %v", err)
+	}
+	if len(fake.deadlines) != 2 || !fake.deadlines[0] || fake.deadlines[1] {
+		t.Errorf("Expected only the timed invocation to have a deadline, got: %v", fake.deadlines)
+	}
+
+	// Cancelled contexts never reach the runner
+	if _, err := query(ctx, "info"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation, got: %v", err)
+	}
+	if len(fake.deadlines) != 2 {
+		t.Errorf("Expected cancelled query not to run, got %d invocations", len(fake.deadlines))
+	}
+}
+
+func TestParseAccessToken(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	token, err := parseAccessToken([]byte(`{"token": "ya29.abc", "expiry": "2025-10-20T12:42:00Z"}`), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token.Token != "ya29.abc" || !token.Expiry.Equal(now.Add(42*time.Minute)) {
+		t.Errorf("Unexpected token parsed from JSON: %+v", token)
+	}
+
+	token, err = parseAccessToken([]byte("ya29.plain\n"), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token.Token != "ya29.plain" || !token.Expiry.Equal(now.Add(defaultTokenLifetime)) {
+		t.Errorf("Expected bare token with default lifetime, got: %+v", token)
+	}
+
+	if _, err := parseAccessToken([]byte("  "), now); err == nil {
+		t.Error("Expected error for empty output")
+	}
+}
+
+func TestIdentityTokenExpiry(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"aud": "https://api.example.com", "exp": 1760965200}`))
+	expiry, err := IdentityTokenExpiry("eyJhbGciOiJSUzI1NiJ9." + payload + ".signature")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !expiry.Equal(time.Unix(1760965200, 0)) {
+		t.Errorf("Unexpected expiry: %v", expiry)
+	}
+
+	if _, err := IdentityTokenExpiry("not-a-jwt"); err == nil {
+		t.Error("Expected error for a token that is not a JWT")
+	}
+}
+
+func TestParseADCIdentity(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected ADCIdentity
+	}{
+		{`{"type": "authorized_user", "account": "jane@example.com", "refresh_token": "x"}`, ADCIdentity{Type: ADCAuthorizedUser, Account: "jane@example.com"}},
+		{
+			`{"type": "impersonated_service_account", "service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken", "source_credentials": {"type": "authorized_user", "account": "jane@example.com"}}`,
+			ADCIdentity{Type: ADCImpersonated, Account: "jane@example.com", ServiceAccount: "sa@p.iam.gserviceaccount.com"},
+		},
+		{
+			`{"type": "impersonated_service_account", "delegates": ["projects/-/serviceAccounts/broker@s.iam.gserviceaccount.com"], "service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken", "source_credentials": {"type": "authorized_user"}}`,
+			ADCIdentity{Type: ADCImpersonated, ServiceAccount: "sa@p.iam.gserviceaccount.com", Delegates: []string{"broker@s.iam.gserviceaccount.com"}},
+		},
+		{`{"type": "service_account", "client_email": "key@p.iam.gserviceaccount.com"}`, ADCIdentity{Type: ADCServiceAccountKey, ServiceAccount: "key@p.iam.gserviceaccount.com"}},
+	}
+	for _, tt := range tests {
+		identity, err := parseADCIdentity([]byte(tt.data))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(identity, tt.expected) {
+			t.Errorf("Expected %+v, got %+v", tt.expected, identity)
+		}
+	}
+
+	if _, err := parseADCIdentity([]byte(`{"type": "external_account"}`)); err == nil {
+		t.Error("Expected unsupported ADC type to be rejected")
+	}
+}
+
+func TestLoginPassesBrowserMode(t *testing.T) {
+	fake := &recordingRunner{}
+	previous := SetRunner(fake)
+	defer SetRunner(previous)
+
+	ctx := context.Background()
+	if err := AuthLoginWithServiceAccount(ctx, "sa@p.iam.gserviceaccount.com", LoginOptions{Browser: BrowserNone}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := AuthLogin(ctx, LoginOptions{Browser: BrowserLaunch}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := AuthLogin(ctx, LoginOptions{Browser: BrowserManual, Account: "jane@example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	drive := "https://www.googleapis.com/auth/drive"
+	if err := AuthLogin(ctx, LoginOptions{Scopes: []string{drive}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := AuthLoginWithServiceAccount(ctx, "broker@s.iam.gserviceaccount.com,sa@p.iam.gserviceaccount.com", LoginOptions{ClientIDFile: "/secrets/client.json"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"gcloud auth login --no-browser",
+		"gcloud auth application-default login --impersonate-service-account sa@p.iam.gserviceaccount.com --no-browser",
+		"gcloud auth login --update-adc",
+		"gcloud auth login jane@example.com --update-adc --no-launch-browser",
+		"gcloud auth login",
+		"gcloud auth application-default login --scopes " + strings.Join(DefaultADCScopes, ",") + "," + drive,
+		"gcloud auth login",
+		"gcloud auth application-default login --impersonate-service-account broker@s.iam.gserviceaccount.com,sa@p.iam.gserviceaccount.com --client-id-file /secrets/client.json",
+	}
+	if len(fake.invocations) != len(expected) {
+		t.Fatalf("Expected %d invocations, got: %+v", len(expected), fake.invocations)
+	}
+	for i, inv := range fake.invocations {
+		if inv.String() != expected[i] || !inv.Interactive {
+			t.Errorf("Expected interactive '%s', got '%s'", expected[i], inv.String())
+		}
+	}
+
+	if _, err := ParseBrowserMode("sometimes"); err == nil {
+		t.Error("Expected unknown browser mode to be rejected")
+	}
+}
+
+func TestIsHeadless(t *testing.T) {
+	linux := runtime.GOOS == "linux"
+	tests := []struct {
+		name     string
+		env      map[string]string
+		headless bool
+	}{
+		{"ssh without display", map[string]string{"SSH_CONNECTION": "10.0.0.1 52000 10.0.0.2 22"}, true},
+		{"ssh tty without display", map[string]string{"SSH_TTY": "/dev/pts/0"}, true},
+		{"ssh with X11 forwarding", map[string]string{"SSH_CONNECTION": "10.0.0.1 52000 10.0.0.2 22", "DISPLAY": "localhost:10.0"}, false},
+		{"X11 desktop", map[string]string{"DISPLAY": ":0"}, false},
+		{"Wayland desktop", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, false},
+		{"Wayland desktop over ssh", map[string]string{"SSH_TTY": "/dev/pts/0", "WAYLAND_DISPLAY": "wayland-0"}, false},
+		{"no display", map[string]string{}, linux},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY", "DISPLAY", "WAYLAND_DISPLAY"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if headless := IsHeadless(); headless != tt.headless {
+				t.Errorf("Expected headless=%v, got %v", tt.headless, headless)
+			}
+		})
+	}
+}
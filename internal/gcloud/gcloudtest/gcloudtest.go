@@ -0,0 +1,95 @@
+// Package gcloudtest provides a scripted gcloud runner for tests.
+package gcloudtest
+
+import (
+	"context"
+	"gcloud-switch/internal/gcloud"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type response struct {
+	output string
+	err    error
+}
+
+// Runner answers gcloud invocations from responses registered by argument prefix
+// and records every invocation. Unmatched invocations succeed with empty output.
+type Runner struct {
+	mu          sync.Mutex
+	prefixes    []string
+	responses   map[string]response
+	invocations []gcloud.Invocation
+}
+
+// New creates an empty Runner
+func New() *Runner {
+	return &Runner{responses: make(map[string]response)}
+}
+
+// Install makes r the runner of the gcloud package until the test finishes
+func (r *Runner) Install(t testing.TB) *Runner {
+	t.Helper()
+	previous := gcloud.SetRunner(r)
+	t.Cleanup(func() { gcloud.SetRunner(previous) })
+	return r
+}
+
+// On answers invocations whose space-joined arguments start with prefix.
+// The longest matching prefix wins.
+func (r *Runner) On(prefix, output string, err error) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.responses[prefix]; !ok {
+		r.prefixes = append(r.prefixes, prefix)
+	}
+	r.responses[prefix] = response{output: output, err: err}
+	return r
+}
+
+// Run implements gcloud.Runner
+func (r *Runner) Run(ctx context.Context, inv gcloud.Invocation) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invocations = append(r.invocations, inv)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	args := strings.Join(inv.Args, " ")
+	best := ""
+	found := false
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(args, prefix) && (!found || len(prefix) > len(best)) {
+			best, found = prefix, true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	resp := r.responses[best]
+	return []byte(resp.output), resp.err
+}
+
+// Invocations returns the space-joined arguments of every invocation so far
+func (r *Runner) Invocations() []string {
+	return r.filter(func(gcloud.Invocation) bool { return true })
+}
+
+// Mutations returns the space-joined arguments of the mutating invocations so far
+func (r *Runner) Mutations() []string {
+	return r.filter(func(inv gcloud.Invocation) bool { return inv.Mutating })
+}
+
+func (r *Runner) filter(keep func(gcloud.Invocation) bool) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var args []string
+	for _, inv := range r.invocations {
+		if keep(inv) {
+			args = append(args, strings.Join(inv.Args, " "))
+		}
+	}
+	return args
+}
@@ -0,0 +1,105 @@
+package gcloud
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// BrowserMode selects how a gcloud login obtains the user's consent
+type BrowserMode string
+
+const (
+	// BrowserLaunch opens a browser on this machine (gcloud's default)
+	BrowserLaunch BrowserMode = "browser"
+	// BrowserNone authorizes on a second machine that has a browser and gcloud installed
+	BrowserNone BrowserMode = "no-browser"
+	// BrowserManual prints a URL to open in any browser and reads back the verification code
+	BrowserManual BrowserMode = "no-launch-browser"
+)
+
+// ParseBrowserMode parses a browser mode name
+func ParseBrowserMode(value string) (BrowserMode, error) {
+	switch mode := BrowserMode(value); mode {
+	case BrowserLaunch, BrowserNone, BrowserManual:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown browser mode '%s' (expected browser, no-browser or no-launch-browser)", value)
+	}
+}
+
+// args returns the gcloud login flags of the mode
+func (m BrowserMode) args() []string {
+	switch m {
+	case BrowserNone:
+		return []string{"--no-browser"}
+	case BrowserManual:
+		return []string{"--no-launch-browser"}
+	default:
+		return nil
+	}
+}
+
+// DefaultADCScopes are the OAuth scopes gcloud requests for ADC when none are given
+var DefaultADCScopes = []string{
+	"openid",
+	"https://www.googleapis.com/auth/userinfo.email",
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/sqlservice.login",
+}
+
+// LoginOptions customizes gcloud logins
+type LoginOptions struct {
+	Browser BrowserMode
+	// Account is the user account to log in with, passed to gcloud as a hint
+	Account string
+	// Scopes are OAuth scopes requested for ADC in addition to DefaultADCScopes
+	Scopes []string
+	// ClientIDFile is an OAuth client secrets file used for ADC instead of gcloud's client
+	ClientIDFile string
+}
+
+// customADC reports whether ADC needs its own login instead of auth login --update-adc
+func (o LoginOptions) customADC() bool {
+	return len(o.Scopes) > 0 || o.ClientIDFile != ""
+}
+
+// adcLoginArgs returns the arguments of a gcloud auth application-default login with the options
+func (o LoginOptions) adcLoginArgs(extra ...string) []string {
+	args := append([]string{"auth", "application-default", "login"}, extra...)
+	if len(o.Scopes) > 0 {
+		scopes := slices.Clone(DefaultADCScopes)
+		for _, scope := range o.Scopes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		args = append(args, "--scopes", strings.Join(scopes, ","))
+	}
+	if o.ClientIDFile != "" {
+		args = append(args, "--client-id-file", o.ClientIDFile)
+	}
+	return append(args, o.Browser.args()...)
+}
+
+// userLoginArgs returns the arguments of a gcloud auth login with the options
+func (o LoginOptions) userLoginArgs(extra ...string) []string {
+	args := []string{"auth", "login"}
+	if o.Account != "" {
+		args = append(args, o.Account)
+	}
+	args = append(args, extra...)
+	return append(args, o.Browser.args()...)
+}
+
+// IsHeadless reports whether no local browser can be opened: an SSH session without
+// X11 forwarding, or a Linux session with neither an X11 nor a Wayland display
+func IsHeadless() bool {
+	hasDisplay := os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_CLIENT") != "" || os.Getenv("SSH_TTY") != "" {
+		return !hasDisplay
+	}
+	return runtime.GOOS == "linux" && !hasDisplay
+}
@@ -0,0 +1,118 @@
+package gcloud
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gcloud-switch/internal/dryrun"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Default timeouts of gcloud invocations. Interactive invocations wait for the user
+// and are only bounded by cancellation of their context.
+const (
+	QueryTimeout  = 30 * time.Second
+	MutateTimeout = 60 * time.Second
+)
+
+// Invocation describes a single execution of the gcloud binary
+type Invocation struct {
+	Args []string
+	// Env holds extra KEY=VALUE environment variables
+	Env []string
+	// Interactive attaches the terminal, for flows such as browser logins
+	Interactive bool
+	// Mutating marks invocations that change gcloud state; they are only recorded in dry-run mode
+	Mutating bool
+	// Timeout bounds the invocation; zero means no timeout
+	Timeout time.Duration
+}
+
+// String renders the invocation as a command line
+func (inv Invocation) String() string {
+	parts := append([]string{}, inv.Env...)
+	parts = append(parts, "gcloud")
+	parts = append(parts, inv.Args...)
+	return strings.Join(parts, " ")
+}
+
+// Runner executes gcloud invocations and returns their standard output.
+// Implementations must stop the invocation when ctx is done.
+type Runner interface {
+	Run(ctx context.Context, inv Invocation) ([]byte, error)
+}
+
+// execRunner runs the real gcloud binary
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, inv Invocation) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "gcloud", inv.Args...)
+	if len(inv.Env) > 0 {
+		cmd.Env = append(os.Environ(), inv.Env...)
+	}
+
+	if inv.Interactive {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return nil, cmd.Run()
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		// Report the timeout or cancellation rather than the killed process
+		return output, fmt.Errorf("gcloud %s: %w", strings.Join(inv.Args, " "), ctx.Err())
+	}
+	if err != nil && stderr.Len() > 0 {
+		return output, fmt.Errorf("%w\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, err
+}
+
+var runner Runner = execRunner{}
+
+// SetRunner replaces the runner used for every gcloud invocation and returns the previous one
+func SetRunner(r Runner) Runner {
+	previous := runner
+	runner = r
+	return previous
+}
+
+// run executes an invocation, recording it instead when it is mutating and dry-run mode is on
+func run(ctx context.Context, inv Invocation) ([]byte, error) {
+	if inv.Mutating && dryrun.Enabled() {
+		dryrun.Record("%s", inv.String())
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if inv.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inv.Timeout)
+		defer cancel()
+	}
+	return runner.Run(ctx, inv)
+}
+
+// query runs a read-only gcloud command
+func query(ctx context.Context, args ...string) ([]byte, error) {
+	return run(ctx, Invocation{Args: args, Timeout: QueryTimeout})
+}
+
+// mutate runs a gcloud command that changes gcloud state
+func mutate(ctx context.Context, args ...string) error {
+	_, err := run(ctx, Invocation{Args: args, Mutating: true, Timeout: MutateTimeout})
+	return err
+}
+
+// interactive runs a gcloud command attached to the terminal
+func interactive(ctx context.Context, args ...string) error {
+	_, err := run(ctx, Invocation{Args: args, Interactive: true, Mutating: true})
+	return err
+}
@@ -1,8 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 // ANSI color codes
@@ -17,93 +22,215 @@ const (
 	colorBold   = "\033[1m"
 )
 
-// Info logs an informational message in cyan
-func Info(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintf(os.Stdout, "%s%s%s\n", colorCyan, msg, colorReset) //nolint:errcheck
-	} else {
-		// Format key-value pairs
-		_, _ = fmt.Fprintf(os.Stdout, "%s%s%s", colorCyan, msg, colorReset) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stdout, " %s%v%s=%s%v%s", colorGray, args[i], colorReset, colorBold, args[i+1], colorReset) //nolint:errcheck
-			}
-		}
-		_, _ = fmt.Fprintln(os.Stdout) //nolint:errcheck
-	}
+// Level is the minimum severity a Logger prints
+type Level int
+
+const (
+	// LevelDebug prints everything, including debug messages (--verbose)
+	LevelDebug Level = iota
+	// LevelInfo prints informational messages and above (default)
+	LevelInfo
+	// LevelWarning prints only warnings and errors (--quiet)
+	LevelWarning
+	// LevelError prints only errors
+	LevelError
+)
+
+// style describes how a kind of message is rendered
+type style struct {
+	name       string
+	level      Level
+	prefix     string
+	color      string
+	valueColor string
+	plain      bool
+	stderr     bool
 }
 
-// Error logs an error message in red
-func Error(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintf(os.Stderr, "%s✗ %s%s\n", colorRed, msg, colorReset) //nolint:errcheck
-	} else {
-		_, _ = fmt.Fprintf(os.Stderr, "%s✗ %s%s", colorRed, msg, colorReset) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stderr, " %s%v%s=%s%v%s", colorGray, args[i], colorReset, colorBold, args[i+1], colorReset) //nolint:errcheck
-			}
-		}
-		_, _ = fmt.Fprintln(os.Stderr) //nolint:errcheck
+var (
+	styleDebug   = style{name: "debug", level: LevelDebug, prefix: "[DEBUG] ", color: colorGray}
+	styleInfo    = style{name: "info", level: LevelInfo, color: colorCyan, valueColor: colorBold}
+	styleSuccess = style{name: "success", level: LevelInfo, prefix: "✓ ", color: colorGreen, valueColor: colorGreen}
+	styleWarning = style{name: "warning", level: LevelWarning, prefix: "⚠ ", color: colorYellow, valueColor: colorYellow}
+	styleError   = style{name: "error", level: LevelError, prefix: "✗ ", color: colorRed, valueColor: colorBold, stderr: true}
+	stylePlain   = style{name: "info", level: LevelInfo, plain: true}
+)
+
+// Logger writes leveled, optionally colored or JSON-lines messages to configurable writers
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+	level  Level
+	color  bool
+	json   bool
+}
+
+// New creates a Logger writing to out (and errOut for errors). Colors are enabled
+// only when out is a terminal and NO_COLOR is not set.
+func New(out, errOut io.Writer) *Logger {
+	return &Logger{
+		out:    out,
+		errOut: errOut,
+		level:  LevelInfo,
+		color:  colorSupported(out),
 	}
 }
 
-// Debug logs a debug message in gray
-func Debug(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintf(os.Stdout, "%s[DEBUG] %s%s\n", colorGray, msg, colorReset) //nolint:errcheck
-	} else {
-		_, _ = fmt.Fprintf(os.Stdout, "%s[DEBUG] %s%s", colorGray, msg, colorReset) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stdout, " %v=%v", args[i], args[i+1]) //nolint:errcheck
-			}
-		}
-		_, _ = fmt.Fprintln(os.Stdout) //nolint:errcheck
+// colorSupported reports whether ANSI colors should be written to w
+func colorSupported(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
+// SetLevel sets the minimum level of printed messages
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetColor forces colors on or off
+func (l *Logger) SetColor(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.color = enabled
+}
+
+// SetJSON switches to JSON-lines output, one object per message
+func (l *Logger) SetJSON(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = enabled
+}
+
+// SetOutput replaces the writers; colors are detected again for the new writer
+func (l *Logger) SetOutput(out, errOut io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+	l.errOut = errOut
+	l.color = colorSupported(out)
+}
+
+// Info logs an informational message in cyan
+func (l *Logger) Info(msg string, args ...any) { l.log(styleInfo, msg, args) }
+
 // Success logs a success message with a checkmark in green
-func Success(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintf(os.Stdout, "%s✓ %s%s\n", colorGreen, msg, colorReset) //nolint:errcheck
-	} else {
-		_, _ = fmt.Fprintf(os.Stdout, "%s✓ %s%s", colorGreen, msg, colorReset) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stdout, " %s%v%s=%s%v%s", colorGray, args[i], colorReset, colorGreen, args[i+1], colorReset) //nolint:errcheck
-			}
-		}
-		_, _ = fmt.Fprintln(os.Stdout) //nolint:errcheck
+func (l *Logger) Success(msg string, args ...any) { l.log(styleSuccess, msg, args) }
+
+// Warning logs a warning message in yellow
+func (l *Logger) Warning(msg string, args ...any) { l.log(styleWarning, msg, args) }
+
+// Error logs an error message in red
+func (l *Logger) Error(msg string, args ...any) { l.log(styleError, msg, args) }
+
+// Debug logs a debug message in gray, only at LevelDebug
+func (l *Logger) Debug(msg string, args ...any) { l.log(styleDebug, msg, args) }
+
+// Plain logs a plain message without any color or prefix
+func (l *Logger) Plain(msg string, args ...any) { l.log(stylePlain, msg, args) }
+
+func (l *Logger) log(s style, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if s.level < l.level {
+		return
+	}
+	w := l.out
+	if s.stderr {
+		w = l.errOut
+	}
+
+	var line string
+	switch {
+	case l.json:
+		line = formatJSON(s, msg, args)
+	case s.plain:
+		line = formatPlain(msg, args)
+	default:
+		line = l.formatText(s, msg, args)
 	}
+	_, _ = io.WriteString(w, line+"\n") //nolint:errcheck
 }
 
-// Warning logs a warning message in yellow
-func Warning(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintf(os.Stdout, "%s⚠ %s%s\n", colorYellow, msg, colorReset) //nolint:errcheck
-	} else {
-		_, _ = fmt.Fprintf(os.Stdout, "%s⚠ %s%s", colorYellow, msg, colorReset) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stdout, " %s%v%s=%s%v%s", colorGray, args[i], colorReset, colorYellow, args[i+1], colorReset) //nolint:errcheck
-			}
+func (l *Logger) formatText(s style, msg string, args []any) string {
+	paint := func(color, text string) string {
+		if !l.color || color == "" {
+			return text
 		}
-		_, _ = fmt.Fprintln(os.Stdout) //nolint:errcheck
+		return color + text + colorReset
+	}
+
+	var b strings.Builder
+	b.WriteString(paint(s.color, s.prefix+msg))
+	for i := 0; i+1 < len(args); i += 2 {
+		b.WriteString(" " + paint(colorGray, fmt.Sprint(args[i])) + "=" + paint(s.valueColor, fmt.Sprint(args[i+1])))
 	}
+	return b.String()
 }
 
-// Plain logs a plain message without any color or prefix
-func Plain(msg string, args ...any) {
-	if len(args) == 0 {
-		_, _ = fmt.Fprintln(os.Stdout, msg) //nolint:errcheck
-	} else {
-		_, _ = fmt.Fprint(os.Stdout, msg) //nolint:errcheck
-		for i := 0; i < len(args); i += 2 {
-			if i+1 < len(args) {
-				_, _ = fmt.Fprintf(os.Stdout, " %v: %v", args[i], args[i+1]) //nolint:errcheck
-			}
+func formatPlain(msg string, args []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v: %v", args[i], args[i+1])
+	}
+	return b.String()
+}
+
+func formatJSON(s style, msg string, args []any) string {
+	entry := map[string]any{
+		"time":  time.Now().UTC().Format(time.RFC3339),
+		"level": s.name,
+		"msg":   msg,
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		value := args[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
 		}
-		_, _ = fmt.Fprintln(os.Stdout) //nolint:errcheck
+		entry[fmt.Sprint(args[i])] = value
 	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Sprintf(`{"level":%q,"msg":%q}`, s.name, msg)
+	}
+	return string(data)
+}
+
+var std = New(os.Stdout, os.Stderr)
+
+// Default returns the package-level Logger used by the helper functions
+func Default() *Logger {
+	return std
 }
+
+// Info logs an informational message in cyan
+func Info(msg string, args ...any) { std.Info(msg, args...) }
+
+// Error logs an error message in red
+func Error(msg string, args ...any) { std.Error(msg, args...) }
+
+// Debug logs a debug message in gray, only in verbose mode
+func Debug(msg string, args ...any) { std.Debug(msg, args...) }
+
+// Success logs a success message with a checkmark in green
+func Success(msg string, args ...any) { std.Success(msg, args...) }
+
+// Warning logs a warning message in yellow
+func Warning(msg string, args ...any) { std.Warning(msg, args...) }
+
+// Plain logs a plain message without any color or prefix
+func Plain(msg string, args ...any) { std.Plain(msg, args...) }
@@ -2,46 +2,23 @@ package logger
 
 import (
 	"bytes"
-	"io"
-	"os"
+	"encoding/json"
 	"strings"
 	"testing"
 )
 
-func captureOutput(f func()) string {
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	f()
-
-	_ = w.Close() //nolint:errcheck
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r) //nolint:errcheck
-	return buf.String()
-}
-
-func captureErrorOutput(f func()) string {
-	old := os.Stderr
-	r, w, _ := os.Pipe()
-	os.Stderr = w
-
-	f()
-
-	_ = w.Close() //nolint:errcheck
-	os.Stderr = old
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r) //nolint:errcheck
-	return buf.String()
+// newTestLogger returns a logger writing to buffers, at the given level
+func newTestLogger(level Level) (l *Logger, out, errOut *bytes.Buffer) {
+	out, errOut = &bytes.Buffer{}, &bytes.Buffer{}
+	l = New(out, errOut)
+	l.SetLevel(level)
+	return l, out, errOut
 }
 
 func TestInfo(t *testing.T) {
-	output := captureOutput(func() {
-		Info("Test message")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Info("Test message")
+	output := out.String()
 
 	if !strings.Contains(output, "Test message") {
 		t.Errorf("Expected output to contain 'Test message', got: %s", output)
@@ -49,9 +26,9 @@ func TestInfo(t *testing.T) {
 }
 
 func TestInfoWithArgs(t *testing.T) {
-	output := captureOutput(func() {
-		Info("Config added", "name", "dev-config", "project_id", "dev-123")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Info("Config added", "name", "dev-config", "project_id", "dev-123")
+	output := out.String()
 
 	if !strings.Contains(output, "Config added") {
 		t.Errorf("Expected output to contain 'Config added', got: %s", output)
@@ -65,9 +42,9 @@ func TestInfoWithArgs(t *testing.T) {
 }
 
 func TestSuccess(t *testing.T) {
-	output := captureOutput(func() {
-		Success("Operation successful")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Success("Operation successful")
+	output := out.String()
 
 	if !strings.Contains(output, "✓") {
 		t.Errorf("Expected output to contain '✓', got: %s", output)
@@ -78,9 +55,9 @@ func TestSuccess(t *testing.T) {
 }
 
 func TestSuccessWithArgs(t *testing.T) {
-	output := captureOutput(func() {
-		Success("Configuration switched", "name", "prod")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Success("Configuration switched", "name", "prod")
+	output := out.String()
 
 	if !strings.Contains(output, "✓") {
 		t.Errorf("Expected output to contain '✓', got: %s", output)
@@ -94,9 +71,9 @@ func TestSuccessWithArgs(t *testing.T) {
 }
 
 func TestError(t *testing.T) {
-	output := captureErrorOutput(func() {
-		Error("Error occurred")
-	})
+	l, _, errOut := newTestLogger(LevelDebug)
+	l.Error("Error occurred")
+	output := errOut.String()
 
 	if !strings.Contains(output, "✗") {
 		t.Errorf("Expected output to contain '✗', got: %s", output)
@@ -107,9 +84,9 @@ func TestError(t *testing.T) {
 }
 
 func TestWarning(t *testing.T) {
-	output := captureOutput(func() {
-		Warning("Warning message")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Warning("Warning message")
+	output := out.String()
 
 	if !strings.Contains(output, "⚠") {
 		t.Errorf("Expected output to contain '⚠', got: %s", output)
@@ -120,9 +97,9 @@ func TestWarning(t *testing.T) {
 }
 
 func TestDebug(t *testing.T) {
-	output := captureOutput(func() {
-		Debug("Debug info")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Debug("Debug info")
+	output := out.String()
 
 	if !strings.Contains(output, "[DEBUG]") {
 		t.Errorf("Expected output to contain '[DEBUG]', got: %s", output)
@@ -133,9 +110,9 @@ func TestDebug(t *testing.T) {
 }
 
 func TestPlain(t *testing.T) {
-	output := captureOutput(func() {
-		Plain("Plain text message")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Plain("Plain text message")
+	output := out.String()
 
 	if !strings.Contains(output, "Plain text message") {
 		t.Errorf("Expected output to contain 'Plain text message', got: %s", output)
@@ -147,9 +124,9 @@ func TestPlain(t *testing.T) {
 }
 
 func TestPlainWithArgs(t *testing.T) {
-	output := captureOutput(func() {
-		Plain("Status", "active", "true")
-	})
+	l, out, _ := newTestLogger(LevelDebug)
+	l.Plain("Status", "active", "true")
+	output := out.String()
 
 	if !strings.Contains(output, "Status") {
 		t.Errorf("Expected output to contain 'Status', got: %s", output)
@@ -180,3 +157,71 @@ func TestColorCodes(t *testing.T) {
 		t.Error("colorYellow should not be empty")
 	}
 }
+
+func TestLevels(t *testing.T) {
+	l, out, errOut := newTestLogger(LevelInfo)
+	l.Debug("hidden debug")
+	l.Info("visible info")
+	if strings.Contains(out.String(), "hidden debug") {
+		t.Errorf("Expected debug messages to be hidden at info level, got: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "visible info") {
+		t.Errorf("Expected info messages at info level, got: %s", out.String())
+	}
+
+	l, out, errOut = newTestLogger(LevelWarning)
+	l.Info("hidden info")
+	l.Success("hidden success")
+	l.Warning("visible warning")
+	l.Error("visible error")
+	if strings.Contains(out.String(), "hidden") {
+		t.Errorf("Expected info and success messages to be hidden in quiet mode, got: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "visible warning") || !strings.Contains(errOut.String(), "visible error") {
+		t.Errorf("Expected warnings and errors in quiet mode, got: %s / %s", out.String(), errOut.String())
+	}
+}
+
+func TestNoColorForNonTerminal(t *testing.T) {
+	l, out, _ := newTestLogger(LevelInfo)
+	l.Info("Config added", "name", "dev")
+	if strings.Contains(out.String(), "\033[") {
+		t.Errorf("Expected no ANSI codes when writing to a buffer, got: %q", out.String())
+	}
+	if out.String() != "Config added name=dev\n" {
+		t.Errorf("Unexpected uncolored output: %q", out.String())
+	}
+
+	l.SetColor(true)
+	out.Reset()
+	l.Info("Config added")
+	if !strings.Contains(out.String(), colorCyan) {
+		t.Errorf("Expected ANSI codes when colors are forced, got: %q", out.String())
+	}
+}
+
+func TestNoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if colorSupported(nil) {
+		t.Error("Expected colors to be disabled when NO_COLOR is set")
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	l, out, _ := newTestLogger(LevelInfo)
+	l.SetJSON(true)
+	l.Success("Configuration switched", "name", "prod")
+	l.Warning("Something odd")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d: %s", len(lines), out.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+	if entry["level"] != "success" || entry["msg"] != "Configuration switched" || entry["name"] != "prod" {
+		t.Errorf("Unexpected JSON entry: %v", entry)
+	}
+}
@@ -0,0 +1,206 @@
+// Package metadata emulates the subset of the GCE metadata server used by Google
+// client libraries to find the project and obtain tokens.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// flavorHeader must be sent with every request and is sent with every response
+	flavorHeader = "Metadata-Flavor"
+	flavorValue  = "Google"
+	// pathPrefix is the root of the emulated API
+	pathPrefix = "/computeMetadata/v1/"
+	// refreshMargin is how long before its expiry a cached access token is replaced
+	refreshMargin = 5 * time.Minute
+)
+
+// Token is an OAuth2 access token
+type Token struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// Server serves metadata for a single configuration. Tokens are obtained from the
+// Token and IdentityToken functions; access tokens are cached until shortly before expiry.
+type Server struct {
+	ProjectID string
+	// NumericProjectID is the project number; empty when unknown
+	NumericProjectID string
+	// Email is the account tokens are issued for
+	Email string
+	// Token obtains a new access token
+	Token func(ctx context.Context) (Token, error)
+	// IdentityToken obtains an ID token for an audience
+	IdentityToken func(ctx context.Context, audience string) (string, error)
+	// Now returns the current time; time.Now when nil
+	Now func() time.Time
+
+	mu     sync.Mutex
+	cached Token
+}
+
+// Handler returns the HTTP handler of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveRoot)
+	mux.HandleFunc(pathPrefix+"project/project-id", s.serveText(func() string { return s.ProjectID }))
+	mux.HandleFunc(pathPrefix+"project/numeric-project-id", s.serveText(func() string { return s.NumericProjectID }))
+	mux.HandleFunc(pathPrefix+"instance/service-accounts/{account}/email", s.serveServiceAccount(s.serveEmail))
+	mux.HandleFunc(pathPrefix+"instance/service-accounts/{account}/token", s.serveServiceAccount(s.serveToken))
+	mux.HandleFunc(pathPrefix+"instance/service-accounts/{account}/identity", s.serveServiceAccount(s.serveIdentity))
+	return requireFlavor(mux)
+}
+
+// requireFlavor rejects requests without the Metadata-Flavor header, as the real
+// server does to keep browsers and proxies from reading tokens
+func requireFlavor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(flavorHeader, flavorValue)
+		if r.Header.Get("X-Forwarded-For") != "" {
+			http.Error(w, "Requests with X-Forwarded-For are not allowed", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get(flavorHeader) != flavorValue {
+			http.Error(w, "Missing Metadata-Flavor:Google header", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveRoot answers the detection probes of client libraries
+func (s *Server) serveRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != pathPrefix {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/text")
+	_, _ = fmt.Fprint(w, "computeMetadata/\n") //nolint:errcheck
+}
+
+func (s *Server) serveText(value func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		text := value()
+		if text == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/text")
+		_, _ = fmt.Fprint(w, text) //nolint:errcheck
+	}
+}
+
+// serveServiceAccount only serves the default account and the account's own email
+func (s *Server) serveServiceAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if account := r.PathValue("account"); account != "default" && account != s.Email {
+			http.NotFound(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) serveEmail(w http.ResponseWriter, r *http.Request) {
+	s.serveText(func() string { return s.Email })(w, r)
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	token, err := s.accessToken(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct { //nolint:errcheck
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}{token.AccessToken, int(token.Expiry.Sub(s.now()).Seconds()), "Bearer"})
+}
+
+func (s *Server) serveIdentity(w http.ResponseWriter, r *http.Request) {
+	audience := r.URL.Query().Get("audience")
+	if audience == "" {
+		http.Error(w, "non-empty audience parameter required", http.StatusBadRequest)
+		return
+	}
+	if s.IdentityToken == nil {
+		http.NotFound(w, r)
+		return
+	}
+	token, err := s.IdentityToken(r.Context(), audience)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/text")
+	_, _ = fmt.Fprint(w, token) //nolint:errcheck
+}
+
+// accessToken returns the cached access token, obtaining a new one when it is about to expire
+func (s *Server) accessToken(ctx context.Context) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached.AccessToken != "" && s.now().Add(refreshMargin).Before(s.cached.Expiry) {
+		return s.cached, nil
+	}
+	if s.Token == nil {
+		return Token{}, errors.New("no token source")
+	}
+	token, err := s.Token(ctx)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	s.cached = token
+	return token, nil
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Serve serves the metadata on listener until ctx is done
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- server.Serve(listener) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// HostEnv lists the environment variables pointing client libraries at a metadata server on addr
+func HostEnv(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	return []string{"GCE_METADATA_HOST=" + addr, "GCE_METADATA_IP=" + addr}
+}
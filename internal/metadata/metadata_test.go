@@ -0,0 +1,178 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string, flavor bool) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if flavor {
+		req.Header.Set("Metadata-Flavor", "Google")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestServerServesMetadata(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	server := &Server{
+		ProjectID:        "my-project",
+		NumericProjectID: "123456789",
+		Email:            "deployer@my-project.iam.gserviceaccount.com",
+		Token: func(context.Context) (Token, error) {
+			calls++
+			return Token{AccessToken: "ya29.token", Expiry: now.Add(time.Hour)}, nil
+		},
+		IdentityToken: func(_ context.Context, audience string) (string, error) {
+			return "id-token-for-" + audience, nil
+		},
+		Now: func() time.Time { return now },
+	}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/computeMetadata/v1/project/project-id", http.StatusOK, "my-project"},
+		{"/computeMetadata/v1/project/numeric-project-id", http.StatusOK, "123456789"},
+		{"/computeMetadata/v1/instance/service-accounts/default/email", http.StatusOK, "deployer@my-project.iam.gserviceaccount.com"},
+		{"/computeMetadata/v1/instance/service-accounts/deployer@my-project.iam.gserviceaccount.com/email", http.StatusOK, "deployer@my-project.iam.gserviceaccount.com"},
+		{"/computeMetadata/v1/instance/service-accounts/other@my-project.iam.gserviceaccount.com/email", http.StatusNotFound, ""},
+		{"/computeMetadata/v1/instance/service-accounts/default/identity?audience=https://api.example.com", http.StatusOK, "id-token-for-https://api.example.com"},
+		{"/computeMetadata/v1/instance/service-accounts/default/identity", http.StatusBadRequest, ""},
+		{"/computeMetadata/v1/instance/zone", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, ts.URL+tt.path, true)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, resp.StatusCode)
+		}
+		if tt.status == http.StatusOK && body != tt.expected {
+			t.Errorf("%s: expected '%s', got '%s'", tt.path, tt.expected, body)
+		}
+		if resp.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("%s: expected the Metadata-Flavor response header", tt.path)
+		}
+	}
+
+	tokenURL := ts.URL + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=x"
+	for range 2 {
+		resp, body := get(t, tokenURL, true)
+		var token struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+			TokenType   string `json:"token_type"`
+		}
+		if err := json.Unmarshal([]byte(body), &token); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected token response %d: %s", resp.StatusCode, body)
+		}
+		if token.AccessToken != "ya29.token" || token.ExpiresIn != 3600 || token.TokenType != "Bearer" {
+			t.Errorf("Unexpected token: %+v", token)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the token to be cached, got %d calls", calls)
+	}
+
+	// Close to its expiry the token is renewed
+	now = now.Add(56 * time.Minute)
+	get(t, tokenURL, true)
+	if calls != 2 {
+		t.Errorf("Expected the token to be renewed, got %d calls", calls)
+	}
+}
+
+func TestServerRequiresMetadataFlavor(t *testing.T) {
+	server := &Server{ProjectID: "my-project"}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, _ := get(t, ts.URL+"/computeMetadata/v1/project/project-id", false)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected requests without Metadata-Flavor to be rejected, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/computeMetadata/v1/project/project-id", nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	forwarded, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	forwarded.Body.Close() //nolint:errcheck,gosec
+	if forwarded.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected proxied requests to be rejected, got %d", forwarded.StatusCode)
+	}
+
+	resp, _ = get(t, ts.URL+"/", true)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Metadata-Flavor") != "Google" {
+		t.Errorf("Expected the detection probe to succeed, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerReportsTokenErrors(t *testing.T) {
+	server := &Server{Token: func(context.Context) (Token, error) { return Token{}, errors.New("reauthentication required") }}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, body := get(t, ts.URL+"/computeMetadata/v1/instance/service-accounts/default/token", true)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(body, "reauthentication required") {
+		t.Errorf("Expected the token error to be reported, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestServeStopsWithContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- (&Server{ProjectID: "my-project"}).Serve(ctx, listener) }()
+
+	resp, body := get(t, "http://"+listener.Addr().String()+"/computeMetadata/v1/project/project-id", true)
+	if resp.StatusCode != http.StatusOK || body != "my-project" {
+		t.Errorf("Unexpected response %d: %s", resp.StatusCode, body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop when the context is done")
+	}
+}
+
+func TestHostEnv(t *testing.T) {
+	if env := HostEnv("0.0.0.0:8080"); env[0] != "GCE_METADATA_HOST=127.0.0.1:8080" {
+		t.Errorf("Expected the unspecified address to be replaced, got %v", env)
+	}
+	if env := HostEnv("127.0.0.1:9090"); env[1] != "GCE_METADATA_IP=127.0.0.1:9090" {
+		t.Errorf("Unexpected environment: %v", env)
+	}
+}
@@ -0,0 +1,123 @@
+// Package output renders command results in machine-readable formats.
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format kinds accepted by --output
+const (
+	Text     = ""
+	JSON     = "json"
+	YAML     = "yaml"
+	Table    = "table"
+	Template = "template"
+)
+
+// Format is a parsed --output value
+type Format struct {
+	Kind     string
+	Template string
+}
+
+// Tabular is implemented by values that can be rendered as an aligned table
+type Tabular interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Parse parses an --output value: json, yaml, table or template=<go template>
+func Parse(value string) (Format, error) {
+	kind, tmpl, hasTemplate := strings.Cut(value, "=")
+	switch kind {
+	case Text, JSON, YAML, Table:
+		if hasTemplate {
+			return Format{}, fmt.Errorf("output format '%s' does not take a value", kind)
+		}
+		return Format{Kind: kind}, nil
+	case Template:
+		if tmpl == "" {
+			return Format{}, errors.New("template output requires a template, e.g. -o 'template={{.name}}'")
+		}
+		return Format{Kind: Template, Template: tmpl}, nil
+	default:
+		return Format{}, fmt.Errorf("unknown output format '%s' (expected json, yaml, table or template=...)", value)
+	}
+}
+
+// IsText reports whether the default human-readable output was requested
+func (f Format) IsText() bool {
+	return f.Kind == Text
+}
+
+// Write renders value to w. Machine formats never contain ANSI escape codes.
+func Write(w io.Writer, f Format, value any) error {
+	switch f.Kind {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case Table:
+		tabular, ok := value.(Tabular)
+		if !ok {
+			return errors.New("table output is not supported for this command")
+		}
+		return writeTable(w, tabular)
+	case Template:
+		return writeTemplate(w, f.Template, value)
+	default:
+		return errors.New("text output must be printed by the command")
+	}
+}
+
+func writeTable(w io.Writer, tabular Tabular) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(tabular.Header(), "\t")); err != nil {
+		return err
+	}
+	for _, row := range tabular.Rows() {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// writeTemplate executes a Go template against the JSON representation of value,
+// so templates use the same field names as the JSON output
+func writeTemplate(w io.Writer, text string, value any) error {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+
+	if err := tmpl.Execute(w, generic); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name      string `json:"name" yaml:"name"`
+	ProjectID string `json:"project_id" yaml:"project_id"`
+}
+
+type items []item
+
+func (i items) Header() []string { return []string{"NAME", "PROJECT"} }
+
+func (i items) Rows() [][]string {
+	var rows [][]string
+	for _, it := range i {
+		rows = append(rows, []string{it.Name, it.ProjectID})
+	}
+	return rows
+}
+
+var sample = items{{Name: "dev", ProjectID: "dev-project"}, {Name: "production", ProjectID: "prod-project"}}
+
+func TestParse(t *testing.T) {
+	for _, value := range []string{"", "json", "yaml", "table", "template={{.name}}"} {
+		if _, err := Parse(value); err != nil {
+			t.Errorf("Expected '%s' to be valid, got error: %v", value, err)
+		}
+	}
+	for _, value := range []string{"xml", "template", "template=", "json=x"} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Expected '%s' to be rejected", value)
+		}
+	}
+
+	format, _ := Parse("template={{.name}}")
+	if format.Kind != Template || format.Template != "{{.name}}" {
+		t.Errorf("Unexpected parsed template format: %+v", format)
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{"json", []string{`"name": "dev"`, `"project_id": "prod-project"`}},
+		{"yaml", []string{"- name: dev", "  project_id: prod-project"}},
+		{"table", []string{"NAME        PROJECT", "production  prod-project"}},
+		{"template={{range .}}{{.name}} {{end}}", []string{"dev production"}},
+	}
+
+	for _, tt := range tests {
+		format, err := Parse(tt.format)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %v", tt.format, err)
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, format, sample); err != nil {
+			t.Fatalf("Failed to write '%s': %v", tt.format, err)
+		}
+		out := buf.String()
+		for _, expected := range tt.expected {
+			if !strings.Contains(out, expected) {
+				t.Errorf("Expected %s output to contain %q, got:\n%s", tt.format, expected, out)
+			}
+		}
+		if strings.Contains(out, "\033[") {
+			t.Errorf("Expected %s output to contain no ANSI codes, got: %q", tt.format, out)
+		}
+	}
+}
+
+func TestWriteTableRequiresTabular(t *testing.T) {
+	format, _ := Parse("table")
+	if err := Write(&bytes.Buffer{}, format, map[string]string{"name": "dev"}); err == nil {
+		t.Error("Expected error for a value that cannot be rendered as a table")
+	}
+}